@@ -23,7 +23,7 @@ func (n *Node) Put(key, value string) {
 
 	// Apply locally
 	n.Store.Apply(key, kvdemo.Value{
-		Data: value,
+		Data: []byte(value),
 		TS:   ts,
 	})
 
@@ -37,7 +37,7 @@ func (n *Node) Put(key, value string) {
 func (n *Node) Receive(key, value string, ts hlc.Timestamp, rtt int64) {
 	n.Clock.Update(ts, rtt)
 	n.Store.Apply(key, kvdemo.Value{
-		Data: value,
+		Data: []byte(value),
 		TS:   ts,
 	})
 }
@@ -85,12 +85,12 @@ func main() {
 	time.Sleep(500 * time.Millisecond)
 
 	fmt.Println("\nFinal state after replication:")
-	fmt.Println("Node A sees:", nodeA.Store.Data())
-	fmt.Println("Node B sees:", nodeB.Store.Data())
-
 	valA := nodeA.Store.Data()["user:1"]
 	valB := nodeB.Store.Data()["user:1"]
 
+	fmt.Printf("Node A sees: user:1=%s\n", valA.Data)
+	fmt.Printf("Node B sees: user:1=%s\n", valB.Data)
+
 	fmt.Printf("\nHLC Timestamps with uncertainty (±ms):\n")
 	fmt.Printf(" Node A: %d ±%dms\n", valA.TS.Physical, valA.TS.Uncertainty)
 	fmt.Printf(" Node B: %d ±%dms\n", valB.TS.Physical, valB.TS.Uncertainty)
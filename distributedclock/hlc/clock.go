@@ -1,6 +1,14 @@
 package hlc
 
 import (
+	"cmp"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"sort"
 	"sync"
 	"time"
 )
@@ -10,8 +18,62 @@ import (
 // MaxClockDriftMillis bounds the expected error of the local physical clock,
 // expressed in milliseconds. It is used as the minimum uncertainty attached
 // to timestamps produced by the clock.
+//
+// Every timestamp returned by Now is strictly greater than the one before
+// it, even when the logical counter would overflow. Instead of wrapping, the
+// clock borrows a millisecond from the future: physical is bumped by one and
+// logical resets to zero. Update carries the same way. Under a stuck or very
+// coarse wall clock this lets the physical component run ahead of real time
+// without bound (one millisecond per 65536 events).
 type Config struct {
 	MaxClockDriftMillis int64 // Maximum tolerated drift of the local clock in milliseconds.
+
+	// OffsetProvider, if set, returns a correction in milliseconds that is
+	// added to every wall clock reading, so physical time can follow a
+	// network-synchronized estimate such as a syncclient offset. Nil means
+	// no correction.
+	OffsetProvider func() int64
+
+	// NowFunc, if set, replaces the system clock as the source of wall
+	// clock readings in milliseconds since Unix epoch, for both Now and
+	// Update. Tests use it to stall or rewind physical time. Nil means the
+	// system clock.
+	NowFunc func() int64
+
+	// DriftRatePPM grows the uncertainty of local timestamps by this many
+	// parts per million of the wall time elapsed since the clock last
+	// synchronized through Update (or since its first reading), modelling a
+	// local oscillator that drifts further the longer it goes without
+	// outside reference. Zero disables growth.
+	DriftRatePPM int64
+
+	// MaxUncertaintyMillis caps the drift-grown uncertainty so it plateaus
+	// instead of growing without bound. A clock whose uncertainty has
+	// reached the cap has gone too long without synchronizing for its
+	// ordering to be trusted: treat it as unsynced. Zero means no cap.
+	MaxUncertaintyMillis int64
+}
+
+// ErrInvalidConfig is returned by Config.Validate and NewValidated when a
+// Config cannot produce meaningful timestamps.
+var ErrInvalidConfig = errors.New("hlc: invalid config")
+
+// Validate reports whether cfg is usable.
+//
+// A negative MaxClockDriftMillis would yield negative uncertainty and make
+// DefinitelyAfter accept orderings it cannot prove, so it is rejected.
+// Zero is valid and means "use the default".
+func (cfg Config) Validate() error {
+	if cfg.MaxClockDriftMillis < 0 {
+		return fmt.Errorf("%w: MaxClockDriftMillis must be non-negative, got %d", ErrInvalidConfig, cfg.MaxClockDriftMillis)
+	}
+	if cfg.DriftRatePPM < 0 || cfg.MaxUncertaintyMillis < 0 {
+		return fmt.Errorf("%w: DriftRatePPM and MaxUncertaintyMillis must be non-negative", ErrInvalidConfig)
+	}
+	if cfg.MaxUncertaintyMillis > 0 && cfg.MaxUncertaintyMillis < cfg.MaxClockDriftMillis {
+		return fmt.Errorf("%w: MaxUncertaintyMillis %d is below MaxClockDriftMillis %d", ErrInvalidConfig, cfg.MaxUncertaintyMillis, cfg.MaxClockDriftMillis)
+	}
+	return nil
 }
 
 // Timestamp represents a Hybrid Logical Clock timestamp with bounded uncertainty.
@@ -25,6 +87,37 @@ type Timestamp struct {
 	Uncertainty int64  // Symmetric uncertainty bound in milliseconds.
 }
 
+// Interval returns the physical bounds [earliest, latest] within which the
+// event stamped by t actually happened. The bounds saturate rather than
+// overflow for extreme uncertainties.
+func (t Timestamp) Interval() (earliest, latest int64) {
+	return saturatingAdd(t.Physical, -t.Uncertainty), saturatingAdd(t.Physical, t.Uncertainty)
+}
+
+// String formats t as "<physical>.<logical>±<uncertainty>ms", for example
+// "1700000000000.3±5ms".
+func (t Timestamp) String() string {
+	return fmt.Sprintf("%d.%d±%dms", t.Physical, t.Logical, t.Uncertainty)
+}
+
+// LogString formats t as "<physical>.<logical>" for log correlation, for
+// example "1714564800123.00007". Physical is zero-padded to 13 digits
+// (milliseconds up to the year 2286) and Logical to 5, so for non-negative
+// physical times the text sorts lexically in (Physical, Logical) order and
+// the millisecond prefix can be grepped directly. Uncertainty is omitted.
+func (t Timestamp) LogString() string {
+	return fmt.Sprintf("%013d.%05d", t.Physical, t.Logical)
+}
+
+// Overlaps reports whether the uncertainty intervals of t and other share
+// at least one millisecond. Intervals are closed, so touching endpoints
+// overlap. Overlapping timestamps cannot be ordered by physical time alone.
+func (t Timestamp) Overlaps(other Timestamp) bool {
+	lo1, hi1 := t.Interval()
+	lo2, hi2 := other.Interval()
+	return lo1 <= hi2 && lo2 <= hi1
+}
+
 // Clock maintains Hybrid Logical Clock state with bounded uncertainty.
 //
 // A Clock is safe for concurrent use by multiple goroutines. It should typically
@@ -35,18 +128,92 @@ type Clock struct {
 	logical     uint16
 	uncertainty int64
 	cfg         Config
+
+	// now reads the local wall clock in milliseconds. It is replaced in
+	// tests to simulate frozen or jumping time.
+	now func() int64
+
+	// lastWall is the previous wall clock reading and backwardJumps counts
+	// readings that went backwards relative to it.
+	lastWall      int64
+	backwardJumps uint64
+
+	// syncedAt is the wall reading DriftRatePPM growth is measured from;
+	// synced is false until the first reading sets it.
+	syncedAt int64
+	synced   bool
+
+	// advanced is closed whenever the state moves forward, waking
+	// WaitForBarrier callers. It is created lazily by waiters.
+	advanced chan struct{}
 }
 
 // New returns a new Clock configured with cfg.
 //
 // If MaxClockDriftMillis is zero, New applies a default of 5 milliseconds.
+// New is lenient: a negative MaxClockDriftMillis is clamped to zero rather
+// than rejected. Use NewValidated to surface misconfiguration instead.
 // The returned Clock starts with physical time set to zero and uncertainty
 // equal to cfg.MaxClockDriftMillis.
 func New(cfg Config) *Clock {
 	if cfg.MaxClockDriftMillis == 0 {
 		cfg.MaxClockDriftMillis = 5 // Default to 5 ms drift if unspecified.
 	}
-	return &Clock{cfg: cfg, uncertainty: cfg.MaxClockDriftMillis}
+	if cfg.MaxClockDriftMillis < 0 {
+		cfg.MaxClockDriftMillis = 0 // Negative drift is meaningless; clamp.
+	}
+	if cfg.MaxUncertaintyMillis > 0 && cfg.MaxUncertaintyMillis < cfg.MaxClockDriftMillis {
+		cfg.MaxUncertaintyMillis = cfg.MaxClockDriftMillis // The cap cannot undercut drift.
+	}
+	now := cfg.NowFunc
+	if now == nil {
+		now = unixMillis
+	}
+	return &Clock{cfg: cfg, uncertainty: cfg.MaxClockDriftMillis, now: now}
+}
+
+// TimeSource returns the current wall-clock time in milliseconds since Unix
+// epoch.
+type TimeSource func() int64
+
+// NewDeterministic returns a Clock with the default Config whose every wall
+// clock read goes through source instead of the system clock.
+//
+// It is intended for tests: driving source explicitly makes sequences of
+// Now and Update calls fully reproducible, including frozen time and
+// backward jumps.
+func NewDeterministic(source TimeSource) *Clock {
+	return New(Config{NowFunc: source})
+}
+
+// NewValidated is like New but returns an error wrapping ErrInvalidConfig
+// when cfg fails Validate.
+func NewValidated(cfg Config) (*Clock, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return New(cfg), nil
+}
+
+// ConfigFingerprint returns a hash of the configuration that affects how
+// this clock's timestamps compare against other nodes', for exchange in a
+// connection handshake.
+//
+// It covers the settings that shape the uncertainty every timestamp
+// carries: the effective MaxClockDriftMillis, DriftRatePPM and
+// MaxUncertaintyMillis. Peers that differ in any of them do not agree on
+// when DefinitelyAfter is safe. OffsetProvider and NowFunc only change how
+// a clock stamps its own events and are ignored. When fingerprints differ,
+// peers should fall back to a conservative skew allowance, such as the
+// larger of the two uncertainty bounds, until the configurations are
+// aligned.
+func (c *Clock) ConfigFingerprint() uint32 {
+	var b [25]byte
+	b[0] = 2 // fingerprint layout version
+	binary.BigEndian.PutUint64(b[1:], uint64(c.cfg.MaxClockDriftMillis))
+	binary.BigEndian.PutUint64(b[9:], uint64(max(c.cfg.DriftRatePPM, 0)))
+	binary.BigEndian.PutUint64(b[17:], uint64(max(c.cfg.MaxUncertaintyMillis, 0)))
+	return crc32.ChecksumIEEE(b[:])
 }
 
 // Now returns a new Timestamp representing the current local HLC time.
@@ -56,25 +223,41 @@ func New(cfg Config) *Clock {
 // clock does not move forward. The returned uncertainty is at least the
 // configured MaxClockDriftMillis.
 func (c *Clock) Now() Timestamp {
+	ts, _ := c.NowDetailed()
+	return ts
+}
+
+// NowDetailed is like Now but also reports whether the wall clock advanced
+// the physical component on this call. false means only the logical counter
+// ticked (or overflowed into physical), so a high rate of
+// false results shows events are being stamped faster than the wall clock's
+// millisecond resolution.
+func (c *Clock) NowDetailed() (Timestamp, bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	now := unixMillis()
-	if now > c.physical {
+	now := c.wall()
+	advanced := now > c.physical
+	if advanced {
 		c.physical = now
 		c.logical = 0
+	} else if c.logical == math.MaxUint16 {
+		// Logical would wrap; carry into physical instead.
+		c.physical++
+		c.logical = 0
 	} else {
 		c.logical++
 	}
 
 	// Local uncertainty is at least the configured maximum drift.
-	c.uncertainty = c.cfg.MaxClockDriftMillis
+	c.uncertainty = c.driftUncertainty(now)
+	c.wake()
 
 	return Timestamp{
 		Physical:    c.physical,
 		Logical:     c.logical,
 		Uncertainty: c.uncertainty,
-	}
+	}, advanced
 }
 
 // Update incorporates a remote timestamp into the local clock state.
@@ -83,19 +266,32 @@ func (c *Clock) Now() Timestamp {
 // estimated round-trip time in milliseconds between nodes. Update advances
 // the local physical and logical components to preserve causality and
 // propagates uncertainty by accounting for remote.Uncertainty and half the RTT.
-func (c *Clock) Update(remote Timestamp, rttMillis int64) {
+//
+// Update returns the clock state right after the merge, read under the same
+// lock, so a follow-on local event can be stamped without a separate Now
+// that might advance further. The result is always greater than remote in
+// (Physical, Logical) order. Relation reports it After remote when the remote
+// physical time was the maximum or local time is beyond the remote
+// uncertainty window; otherwise the two are Concurrent.
+func (c *Clock) Update(remote Timestamp, rttMillis int64) Timestamp {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	now := unixMillis()
+	now := c.wall()
+	local := c.decayedUncertainty(now)
+	c.syncedAt, c.synced = now, true
 	maxPhysical := max(c.physical, max(remote.Physical, now))
 
+	var prev uint16
 	switch {
 	case maxPhysical == c.physical && maxPhysical == remote.Physical:
-		c.logical = maxUint16(c.logical, remote.Logical) + 1
+		prev = maxUint16(c.logical, remote.Logical)
+		c.logical = prev + 1
 	case maxPhysical == c.physical:
+		prev = c.logical
 		c.logical++
 	case maxPhysical == remote.Physical:
+		prev = remote.Logical
 		c.logical = remote.Logical + 1
 	default:
 		c.logical = 0
@@ -103,20 +299,218 @@ func (c *Clock) Update(remote Timestamp, rttMillis int64) {
 
 	c.physical = maxPhysical
 
-	// Propagate uncertainty: take the maximum of local uncertainty and
-	// the remote uncertainty extended by half the observed RTT.
-	remoteUncertainty := remote.Uncertainty + rttMillis/2
-	c.uncertainty = max(c.uncertainty, remoteUncertainty)
+	// A wrapped logical counter carries into physical.
+	if prev == math.MaxUint16 {
+		c.physical++
+	}
+
+	// Propagate uncertainty: take the maximum of the (decayed) local
+	// uncertainty and the remote uncertainty extended by half the observed
+	// RTT. Corrupt or adversarial inputs must not wrap the bound negative,
+	// which would make every comparison against it look definite.
+	remoteUncertainty := saturatingAdd(max(remote.Uncertainty, 0), max(rttMillis/2, 0))
+	c.uncertainty = max(local, remoteUncertainty)
+	c.wake()
+
+	return Timestamp{
+		Physical:    c.physical,
+		Logical:     c.logical,
+		Uncertainty: c.uncertainty,
+	}
 }
 
 // Uncertainty returns the current uncertainty bound of the clock in milliseconds.
 //
 // The returned value reflects the maximum of the local drift configuration and
-// any remote uncertainty observed through Update calls.
+// any remote uncertainty observed through Update calls, decayed as described
+// for UncertaintyAt at the current wall clock reading.
 func (c *Clock) Uncertainty() int64 {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	return c.uncertainty
+	return c.decayedUncertainty(c.wall())
+}
+
+// UncertaintyAt returns the clock's uncertainty bound as of the wall clock
+// reading now, in milliseconds since Unix epoch.
+//
+// Uncertainty inflated by a remote timestamp in Update relaxes by one
+// millisecond per millisecond of wall time elapsed since that Update, as
+// the local clock moves past the remote event, and is floored at the local
+// drift bound (MaxClockDriftMillis, grown by DriftRatePPM if configured).
+func (c *Clock) UncertaintyAt(now int64) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.decayedUncertainty(now)
+}
+
+// decayedUncertainty returns c.uncertainty relaxed by the wall time elapsed
+// between the last Update and now, floored at the drift bound. Callers must
+// hold c.mu.
+func (c *Clock) decayedUncertainty(now int64) int64 {
+	floor := c.driftUncertainty(now)
+	u := c.uncertainty
+	if c.synced && now > c.syncedAt {
+		u = saturatingAdd(u, -(now - c.syncedAt))
+	}
+	return max(u, floor)
+}
+
+// WaitForBarrier blocks until the clock has incorporated token, that is
+// until its (Physical, Logical) state is at or beyond token's, or ctx is
+// done. It returns ctx.Err() on cancellation.
+//
+// Followers use this for causal reads: a client carrying the timestamp of
+// its last observed write waits until the local clock has caught up, either
+// through Update with replicated data or through local Now calls.
+func (c *Clock) WaitForBarrier(ctx context.Context, token Timestamp) error {
+	for {
+		c.mu.Lock()
+		if c.physical > token.Physical || (c.physical == token.Physical && c.logical >= token.Logical) {
+			c.mu.Unlock()
+			return nil
+		}
+		if c.advanced == nil {
+			c.advanced = make(chan struct{})
+		}
+		ch := c.advanced
+		c.mu.Unlock()
+
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// CanCommit reports whether the local clock is guaranteed to be past ts,
+// that is whether the earliest possible time of a local reading exceeds
+// ts.Physical + ts.Uncertainty. When it is not, the returned duration is
+// how much longer the wall clock must advance before it will be, capped at
+// the longest representable time.Duration.
+//
+// This is Spanner-style commit-wait: a write stamped ts may only be
+// reported as committed once CanCommit holds, so that no timestamp taken
+// anywhere afterwards can be ordered before it.
+func (c *Clock) CanCommit(ts Timestamp) (bool, time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.wall()
+	earliest := saturatingAdd(now, -c.decayedUncertainty(now))
+	_, latest := ts.Interval()
+	if earliest > latest {
+		return true, 0
+	}
+	// The gap is exact in uint64 even when latest-earliest overflows int64
+	gap := uint64(latest) - uint64(earliest)
+	if gap >= math.MaxInt64/uint64(time.Millisecond) {
+		return false, math.MaxInt64
+	}
+	return false, time.Duration(gap+1) * time.Millisecond
+}
+
+// WaitUntilPast blocks until CanCommit(ts) holds, sleeping for the reported
+// remaining duration between checks. Under an injected time source that
+// does not advance it never returns; use WaitUntilPastContext to bound the
+// wait.
+func (c *Clock) WaitUntilPast(ts Timestamp) {
+	_ = c.WaitUntilPastContext(context.Background(), ts)
+}
+
+// WaitUntilPastContext is like WaitUntilPast but gives up when ctx is done,
+// returning ctx.Err(). It returns nil once CanCommit(ts) holds.
+func (c *Clock) WaitUntilPastContext(ctx context.Context, ts Timestamp) error {
+	for {
+		ok, wait := c.CanCommit(ts)
+		if ok {
+			return nil
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// wake releases WaitForBarrier callers. Callers must hold c.mu.
+func (c *Clock) wake() {
+	if c.advanced != nil {
+		close(c.advanced)
+		c.advanced = nil
+	}
+}
+
+// wall reads the time source, applies the configured offset and tracks
+// backward jumps. Callers must hold c.mu.
+func (c *Clock) wall() int64 {
+	now := c.now()
+	if c.cfg.OffsetProvider != nil {
+		now = saturatingAdd(now, c.cfg.OffsetProvider())
+	}
+	if now < c.lastWall {
+		c.backwardJumps++
+	}
+	c.lastWall = now
+	return now
+}
+
+// driftUncertainty returns the uncertainty of a local reading at now: the
+// configured drift, grown by DriftRatePPM of the time since the last sync
+// and capped at MaxUncertaintyMillis. Callers must hold c.mu.
+func (c *Clock) driftUncertainty(now int64) int64 {
+	if !c.synced {
+		c.syncedAt, c.synced = now, true
+	}
+	u := c.cfg.MaxClockDriftMillis
+	if elapsed := now - c.syncedAt; c.cfg.DriftRatePPM > 0 && elapsed > 0 {
+		// Split elapsed so the product cannot overflow for sane rates
+		growth := elapsed/1e6*c.cfg.DriftRatePPM + elapsed%1e6*c.cfg.DriftRatePPM/1e6
+		u = saturatingAdd(u, growth)
+	}
+	if limit := c.cfg.MaxUncertaintyMillis; limit > 0 {
+		u = min(u, limit)
+	}
+	return u
+}
+
+// ClockState is a point-in-time snapshot of a Clock for debugging dumps.
+//
+// Physical, Logical and Uncertainty are the HLC state; WallNow is the local
+// wall clock read while taking the snapshot, so Physical-WallNow shows how
+// far the HLC runs ahead of local time. BackwardJumps counts how many times
+// the wall clock was observed moving backwards.
+type ClockState struct {
+	Physical      int64
+	Logical       uint16
+	Uncertainty   int64
+	WallNow       int64
+	BackwardJumps uint64
+}
+
+// String formats the state for logs, e.g.
+// "hlc=1700000000123.4 ±5ms wall=1700000000120 ahead=3ms backward_jumps=0".
+func (s ClockState) String() string {
+	return fmt.Sprintf("hlc=%d.%d ±%dms wall=%d ahead=%dms backward_jumps=%d",
+		s.Physical, s.Logical, s.Uncertainty, s.WallNow, s.Physical-s.WallNow, s.BackwardJumps)
+}
+
+// DebugState returns a consistent snapshot of the clock, taken under the
+// lock. It does not advance the clock.
+func (c *Clock) DebugState() ClockState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return ClockState{
+		Physical:      c.physical,
+		Logical:       c.logical,
+		Uncertainty:   c.uncertainty,
+		WallNow:       c.wall(),
+		BackwardJumps: c.backwardJumps,
+	}
 }
 
 // unixMillis returns the current wall-clock time in milliseconds since Unix epoch.
@@ -124,6 +518,18 @@ func unixMillis() int64 {
 	return time.Now().UnixNano() / 1e6
 }
 
+// saturatingAdd returns a+b clamped to [math.MinInt64, math.MaxInt64]
+// instead of wrapping around.
+func saturatingAdd(a, b int64) int64 {
+	switch {
+	case b > 0 && a > math.MaxInt64-b:
+		return math.MaxInt64
+	case b < 0 && a < math.MinInt64-b:
+		return math.MinInt64
+	}
+	return a + b
+}
+
 // maxUint16 returns the larger of a and b.
 func maxUint16(a, b uint16) uint16 {
 	if a > b {
@@ -132,6 +538,79 @@ func maxUint16(a, b uint16) uint16 {
 	return b
 }
 
+// Ordering describes how two timestamps relate once uncertainty is taken
+// into account.
+type Ordering int
+
+const (
+	// Concurrent means the uncertainty windows overlap and neither timestamp
+	// can be proven to precede the other.
+	Concurrent Ordering = iota
+	// Before means the first timestamp definitely precedes the second.
+	Before
+	// After means the first timestamp definitely follows the second.
+	After
+	// Equal means both timestamps have identical physical and logical components.
+	Equal
+)
+
+// String returns the name of the ordering.
+func (o Ordering) String() string {
+	switch o {
+	case Before:
+		return "Before"
+	case After:
+		return "After"
+	case Equal:
+		return "Equal"
+	default:
+		return "Concurrent"
+	}
+}
+
+// Relation classifies the ordering of a relative to b.
+//
+// It is the single source of truth for uncertainty-aware comparisons:
+// a is After b when its physical time exceeds b's latest possible time
+// (b.Physical + b.Uncertainty), or when both share the same physical time
+// and a has the larger logical counter. Before is the mirror image. Equal
+// requires identical physical and logical components; uncertainty is not
+// compared. Everything else is Concurrent.
+func Relation(a, b Timestamp) Ordering {
+	switch {
+	case a.Physical == b.Physical && a.Logical == b.Logical:
+		return Equal
+	case after(a, b):
+		return After
+	case after(b, a):
+		return Before
+	default:
+		return Concurrent
+	}
+}
+
+// Compare orders a and b by physical time, then logical counter, ignoring
+// uncertainty. It returns -1, 0 or +1 and gives the total order needed for
+// sorting, unlike Relation, which reports overlapping windows as
+// Concurrent.
+func Compare(a, b Timestamp) int {
+	if c := cmp.Compare(a.Physical, b.Physical); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Logical, b.Logical)
+}
+
+// after implements the one-directional ordering rule used by Relation.
+func after(ts1, ts2 Timestamp) bool {
+	if _, latest := ts2.Interval(); ts1.Physical > latest {
+		return true
+	}
+	if ts1.Physical == ts2.Physical && ts1.Logical > ts2.Logical {
+		return true
+	}
+	return false
+}
+
 // DefinitelyAfter reports whether ts1 is guaranteed to have occurred after ts2.
 //
 // This holds only if the earliest possible time for ts1 is strictly greater
@@ -139,11 +618,111 @@ func maxUint16(a, b uint16) uint16 {
 // When DefinitelyAfter returns false, the relative ordering of ts1 and ts2
 // is ambiguous and must not be treated as strictly ordered.
 func DefinitelyAfter(ts1, ts2 Timestamp) bool {
-	if ts1.Physical > ts2.Physical+ts2.Uncertainty {
-		return true
+	return Relation(ts1, ts2) == After
+}
+
+// DefinitelyBefore reports whether ts1 is guaranteed to have occurred before
+// ts2. It is the mirror image of DefinitelyAfter.
+func DefinitelyBefore(ts1, ts2 Timestamp) bool {
+	return DefinitelyAfter(ts2, ts1)
+}
+
+// IsConcurrent reports whether neither timestamp is definitely after the
+// other, which is when writes stamped with them conflict. It is symmetric,
+// and unlike Relation it also reports timestamps with identical physical and
+// logical components as concurrent, since writers on different nodes can
+// produce them independently. The name avoids the Concurrent Ordering.
+func IsConcurrent(ts1, ts2 Timestamp) bool {
+	return !DefinitelyAfter(ts1, ts2) && !DefinitelyAfter(ts2, ts1)
+}
+
+// CrossClusterAfter reports whether a, stamped in one cluster, definitely
+// happened after b, stamped in another cluster with its own HLC lineage.
+//
+// Logical counters of independent clusters are unrelated, so only physical
+// time is compared, against a shared wall-clock reference: a is after b
+// only when its earliest possible time exceeds b's latest possible time by
+// more than clusterSkewMillis, the configured bound on how far the
+// clusters' clocks may disagree. In other words the physical gap must
+// exceed both uncertainties plus the skew. Otherwise the timestamps must be
+// treated as concurrent. A negative skew is treated as zero.
+func CrossClusterAfter(a, b Timestamp, clusterSkewMillis int64) bool {
+	earliest, _ := a.Interval()
+	_, latest := b.Interval()
+	return earliest > saturatingAdd(latest, max(clusterSkewMillis, 0))
+}
+
+// ApproxEqual reports whether a and b fall in the same instant for
+// bucketing purposes: their physical times differ by at most the larger of
+// the two uncertainties. Logical counters are ignored.
+//
+// This is deliberately looser than Relation's Equal, which demands identical
+// physical and logical components. ApproxEqual is not transitive, so
+// aggregation windows should be anchored on one reference timestamp.
+func ApproxEqual(a, b Timestamp) bool {
+	d := a.Physical - b.Physical
+	if d < 0 {
+		d = -d
 	}
-	if ts1.Physical == ts2.Physical && ts1.Logical > ts2.Logical {
-		return true
+	return d <= max(a.Uncertainty, b.Uncertainty)
+}
+
+// Barrier returns a timestamp that is Equal to or After every observed
+// timestamp according to Relation, suitable for propagating an "everything up
+// to here has been seen" low-water mark.
+//
+// The barrier takes the maximum physical, logical and uncertainty of its
+// inputs. If any input's uncertainty window reaches the maximum physical
+// time, that alone would leave the two Concurrent, so the physical component
+// is pushed one millisecond past the latest possible time of every input.
+// Barrier with no inputs returns the zero Timestamp.
+func Barrier(observed ...Timestamp) Timestamp {
+	if len(observed) == 0 {
+		return Timestamp{}
 	}
-	return false
+
+	var b Timestamp
+	b.Physical = observed[0].Physical
+	for _, ts := range observed {
+		b.Physical = max(b.Physical, ts.Physical)
+		b.Logical = maxUint16(b.Logical, ts.Logical)
+		b.Uncertainty = max(b.Uncertainty, ts.Uncertainty)
+	}
+
+	// Inputs strictly below the max are only dominated beyond their window.
+	overlaps := false
+	for _, ts := range observed {
+		if ts.Physical < b.Physical && saturatingAdd(ts.Physical, ts.Uncertainty) >= b.Physical {
+			overlaps = true
+			break
+		}
+	}
+	if overlaps {
+		for _, ts := range observed {
+			b.Physical = max(b.Physical, saturatingAdd(saturatingAdd(ts.Physical, ts.Uncertainty), 1))
+		}
+	}
+	return b
+}
+
+// SafeTimestamp returns the quorum low-water mark of acks: the highest
+// timestamp such that at least quorum acks are at or above it in
+// (Physical, Logical) order. Everything up to that point has been
+// acknowledged by a quorum and can be served by committed reads.
+//
+// It reports false when quorum is not positive or fewer than quorum acks
+// are available. acks is not modified.
+func SafeTimestamp(acks []Timestamp, quorum int) (Timestamp, bool) {
+	if quorum <= 0 || len(acks) < quorum {
+		return Timestamp{}, false
+	}
+
+	sorted := append([]Timestamp(nil), acks...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Physical != sorted[j].Physical {
+			return sorted[i].Physical > sorted[j].Physical
+		}
+		return sorted[i].Logical > sorted[j].Logical
+	})
+	return sorted[quorum-1], true
 }
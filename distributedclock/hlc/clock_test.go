@@ -0,0 +1,707 @@
+package hlc
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+// frozen returns a time source stuck at ms.
+func frozen(ms int64) func() int64 {
+	return func() int64 { return ms }
+}
+
+// less orders timestamps by (Physical, Logical).
+func less(a, b Timestamp) bool {
+	if a.Physical != b.Physical {
+		return a.Physical < b.Physical
+	}
+	return a.Logical < b.Logical
+}
+
+// Now never repeats a timestamp, even across logical overflow
+func TestLogicalOverflowFrozenClock(t *testing.T) {
+	c := New(Config{NowFunc: frozen(1_000)})
+
+	const N = 1_000_000
+	prev := c.Now()
+	for i := 1; i < N; i++ {
+		ts := c.Now()
+		if !less(prev, ts) {
+			t.Fatalf("call %d: %v not strictly after %v", i, ts, prev)
+		}
+		prev = ts
+	}
+
+	// 1M events at 65536 per millisecond borrow ~15ms from the future.
+	t.Logf("final: physical=%d logical=%d", prev.Physical, prev.Logical)
+	if prev.Physical <= 1_000 {
+		t.Fatalf("expected physical to carry past frozen time, got %d", prev.Physical)
+	}
+}
+
+// Update carries overflow instead of wrapping
+func TestUpdateLogicalOverflow(t *testing.T) {
+	c := New(Config{NowFunc: frozen(1_000)})
+
+	remote := Timestamp{Physical: 1_000, Logical: 65535}
+	if got := c.Update(remote, 0); !less(remote, got) {
+		t.Fatalf("Update returned %v, not after remote %v", got, remote)
+	}
+	ts := c.Now()
+	if ts.Physical != 1_001 {
+		t.Fatalf("expected carry into physical, got %d.%d", ts.Physical, ts.Logical)
+	}
+}
+
+// Relation distinguishes all four orderings
+func TestRelation(t *testing.T) {
+	base := Timestamp{Physical: 1_000, Logical: 2, Uncertainty: 5}
+
+	cases := []struct {
+		name string
+		a, b Timestamp
+		want Ordering
+	}{
+		{"equal", base, base, Equal},
+		{"equal ignores uncertainty", base, Timestamp{Physical: 1_000, Logical: 2, Uncertainty: 50}, Equal},
+		{"after beyond uncertainty", Timestamp{Physical: 1_006}, base, After},
+		{"before beyond uncertainty", base, Timestamp{Physical: 1_006}, Before},
+		{"after by logical", Timestamp{Physical: 1_000, Logical: 3}, base, After},
+		{"before by logical", base, Timestamp{Physical: 1_000, Logical: 3}, Before},
+		{"concurrent within window", Timestamp{Physical: 1_003}, base, Concurrent},
+		{"concurrent at boundary", Timestamp{Physical: 1_005}, base, Concurrent},
+	}
+
+	for _, tc := range cases {
+		if got := Relation(tc.a, tc.b); got != tc.want {
+			t.Errorf("%s: Relation = %v, want %v", tc.name, got, tc.want)
+		}
+		if got := DefinitelyAfter(tc.a, tc.b); got != (tc.want == After) {
+			t.Errorf("%s: DefinitelyAfter = %v", tc.name, got)
+		}
+	}
+}
+
+// Negative drift is rejected by NewValidated and clamped by New
+func TestConfigValidate(t *testing.T) {
+	cfg := Config{MaxClockDriftMillis: -3}
+
+	if err := cfg.Validate(); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("Validate: expected ErrInvalidConfig, got %v", err)
+	}
+	if c, err := NewValidated(cfg); err == nil || c != nil {
+		t.Fatalf("NewValidated accepted negative drift")
+	}
+
+	c := New(cfg)
+	if u := c.Uncertainty(); u != 0 {
+		t.Fatalf("New: expected drift clamped to 0, got %d", u)
+	}
+	if ts := c.Now(); ts.Uncertainty < 0 {
+		t.Fatalf("New: negative uncertainty %d", ts.Uncertainty)
+	}
+
+	if _, err := NewValidated(Config{}); err != nil {
+		t.Fatalf("zero config rejected: %v", err)
+	}
+}
+
+// Barrier dominates every observed timestamp
+func TestBarrier(t *testing.T) {
+	sets := [][]Timestamp{
+		{{Physical: 100, Logical: 1, Uncertainty: 5}, {Physical: 200, Logical: 0, Uncertainty: 5}},
+		{{Physical: 100, Logical: 7}, {Physical: 100, Logical: 2}, {Physical: 99, Logical: 9}},
+		{{Physical: 100, Uncertainty: 50}, {Physical: 120, Logical: 3, Uncertainty: 1}, {Physical: 60, Uncertainty: 200}},
+		{{Physical: 42, Logical: 4, Uncertainty: 0}},
+	}
+
+	for i, set := range sets {
+		b := Barrier(set...)
+		for _, ts := range set {
+			if rel := Relation(b, ts); rel != After && rel != Equal {
+				t.Errorf("set %d: barrier %+v is %v relative to %+v", i, b, rel, ts)
+			}
+		}
+	}
+
+	if b := Barrier(); b != (Timestamp{}) {
+		t.Fatalf("empty barrier: %+v", b)
+	}
+
+	// A near-max uncertainty window saturates the pushed physical time
+	wide := []Timestamp{{Physical: 100, Uncertainty: math.MaxInt64 - 50}, {Physical: 200}}
+	if b := Barrier(wide...); b.Physical != math.MaxInt64 {
+		t.Fatalf("wide barrier physical = %d, want MaxInt64", b.Physical)
+	}
+}
+
+// Near-max remote uncertainty saturates instead of wrapping negative
+func TestUpdateUncertaintySaturates(t *testing.T) {
+	c := New(Config{})
+	c.now = frozen(1_000)
+
+	remote := Timestamp{Physical: 1_000, Uncertainty: math.MaxInt64 - 10}
+	ts := c.Update(remote, 1_000)
+	if ts.Uncertainty != math.MaxInt64 {
+		t.Fatalf("uncertainty = %d, want saturation at MaxInt64", ts.Uncertainty)
+	}
+	if c.Uncertainty() < 0 {
+		t.Fatalf("clock uncertainty wrapped negative: %d", c.Uncertainty())
+	}
+
+	// An overflowing window must never make timestamps look ordered
+	later := Timestamp{Physical: 1_000_000}
+	if DefinitelyAfter(later, ts) {
+		t.Fatalf("%+v definitely after saturated %+v", later, ts)
+	}
+}
+
+// OffsetProvider shifts physical time by the reported offset
+func TestOffsetProvider(t *testing.T) {
+	offset := int64(250)
+	c := New(Config{OffsetProvider: func() int64 { return offset }})
+	c.now = frozen(10_000)
+
+	if ts := c.Now(); ts.Physical != 10_250 {
+		t.Fatalf("Now physical = %d, want 10250", ts.Physical)
+	}
+
+	offset = 1_000
+	if ts := c.Update(Timestamp{Physical: 10_500}, 0); ts.Physical != 11_000 || ts.Logical != 0 {
+		t.Fatalf("Update = %+v, want physical 11000 from corrected wall clock", ts)
+	}
+
+	plain := New(Config{})
+	plain.now = frozen(10_000)
+	if ts := plain.Now(); ts.Physical != 10_000 {
+		t.Fatalf("nil provider shifted time: %+v", ts)
+	}
+}
+
+// LogString sorts lexically in (Physical, Logical) order
+func TestLogString(t *testing.T) {
+	if got := (Timestamp{Physical: 1714564800123, Logical: 7}).LogString(); got != "1714564800123.00007" {
+		t.Fatalf("LogString = %q", got)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	ts := make([]Timestamp, 1_000)
+	for i := range ts {
+		ts[i] = Timestamp{
+			Physical:    rng.Int63n(1_000) * rng.Int63n(10_000_000_000),
+			Logical:     uint16(rng.Intn(1 << 16)),
+			Uncertainty: rng.Int63n(100),
+		}
+	}
+
+	sort.Slice(ts, func(i, j int) bool { return ts[i].LogString() < ts[j].LogString() })
+	for i := 1; i < len(ts); i++ {
+		if less(ts[i], ts[i-1]) {
+			t.Fatalf("%s sorts after %s but is earlier", ts[i].LogString(), ts[i-1].LogString())
+		}
+	}
+}
+
+// NowDetailed reports a physical tick only when the wall clock moved
+func TestNowDetailed(t *testing.T) {
+	wall := int64(1_000)
+	c := New(Config{})
+	c.now = func() int64 { return wall }
+
+	if ts, advanced := c.NowDetailed(); !advanced || ts.Physical != 1_000 || ts.Logical != 0 {
+		t.Fatalf("first call = %+v, %v; want physical tick", ts, advanced)
+	}
+	for i := 1; i <= 5; i++ {
+		ts, advanced := c.NowDetailed()
+		if advanced || ts.Logical != uint16(i) {
+			t.Fatalf("frozen call %d = %+v, %v; want logical-only tick", i, ts, advanced)
+		}
+	}
+
+	wall++
+	if ts, advanced := c.NowDetailed(); !advanced || ts.Logical != 0 {
+		t.Fatalf("after wall advance = %+v, %v", ts, advanced)
+	}
+}
+
+// ApproxEqual groups timestamps within the larger uncertainty
+func TestApproxEqual(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b Timestamp
+		want bool
+	}{
+		{"within", Timestamp{Physical: 1_000, Uncertainty: 5}, Timestamp{Physical: 1_003, Logical: 9, Uncertainty: 2}, true},
+		{"at boundary", Timestamp{Physical: 1_000, Uncertainty: 5}, Timestamp{Physical: 1_005}, true},
+		{"boundary from larger side", Timestamp{Physical: 1_010}, Timestamp{Physical: 1_000, Uncertainty: 10}, true},
+		{"outside", Timestamp{Physical: 1_000, Uncertainty: 5}, Timestamp{Physical: 1_006, Uncertainty: 3}, false},
+		{"no uncertainty", Timestamp{Physical: 1_000, Logical: 1}, Timestamp{Physical: 1_000, Logical: 2}, true},
+	}
+	for _, c := range cases {
+		if got := ApproxEqual(c.a, c.b); got != c.want {
+			t.Errorf("%s: ApproxEqual = %v, want %v", c.name, got, c.want)
+		}
+		if got := ApproxEqual(c.b, c.a); got != c.want {
+			t.Errorf("%s: ApproxEqual is not symmetric", c.name)
+		}
+	}
+}
+
+// Fingerprints match for equal drift configuration only
+func TestConfigFingerprint(t *testing.T) {
+	a := New(Config{MaxClockDriftMillis: 10})
+	b := New(Config{MaxClockDriftMillis: 10, OffsetProvider: func() int64 { return 0 }})
+	c := New(Config{MaxClockDriftMillis: 20})
+
+	if a.ConfigFingerprint() != b.ConfigFingerprint() {
+		t.Fatalf("identical drift configs have different fingerprints")
+	}
+	if a.ConfigFingerprint() == c.ConfigFingerprint() {
+		t.Fatalf("different drift configs share fingerprint %08x", a.ConfigFingerprint())
+	}
+
+	// The default drift is applied before fingerprinting
+	if New(Config{}).ConfigFingerprint() != New(Config{MaxClockDriftMillis: 5}).ConfigFingerprint() {
+		t.Fatalf("default config fingerprint differs from explicit default")
+	}
+
+	// Drift growth and its cap change the carried uncertainty too
+	rate := New(Config{MaxClockDriftMillis: 10, DriftRatePPM: 100})
+	capped := New(Config{MaxClockDriftMillis: 10, DriftRatePPM: 100, MaxUncertaintyMillis: 50})
+	for _, other := range []*Clock{rate, capped} {
+		if a.ConfigFingerprint() == other.ConfigFingerprint() {
+			t.Fatalf("drift growth settings not covered by fingerprint")
+		}
+	}
+	if rate.ConfigFingerprint() == capped.ConfigFingerprint() {
+		t.Fatalf("uncertainty cap not covered by fingerprint")
+	}
+}
+
+// Compare orders by physical then logical and ignores uncertainty
+func TestCompare(t *testing.T) {
+	a := Timestamp{Physical: 100, Logical: 1, Uncertainty: 50}
+	cases := []struct {
+		b    Timestamp
+		want int
+	}{
+		{Timestamp{Physical: 100, Logical: 1}, 0},
+		{Timestamp{Physical: 100, Logical: 2}, -1},
+		{Timestamp{Physical: 99, Logical: 7}, 1},
+		{Timestamp{Physical: 120}, -1},
+	}
+	for _, c := range cases {
+		if got := Compare(a, c.b); got != c.want {
+			t.Fatalf("Compare(%v, %v) = %d, want %d", a, c.b, got, c.want)
+		}
+		if got := Compare(c.b, a); got != -c.want {
+			t.Fatalf("Compare(%v, %v) = %d, want %d", c.b, a, got, -c.want)
+		}
+	}
+}
+
+// Drift-grown uncertainty rises with elapsed time and plateaus at the cap
+func TestMaxUncertaintyMillis(t *testing.T) {
+	wall := int64(1_000)
+	c := New(Config{MaxClockDriftMillis: 5, DriftRatePPM: 100_000, MaxUncertaintyMillis: 50})
+	c.now = func() int64 { return wall }
+
+	prev := c.Now().Uncertainty
+	if prev != 5 {
+		t.Fatalf("fresh clock uncertainty %d, want the drift bound", prev)
+	}
+	for i := 0; i < 20; i++ {
+		wall += 100
+		u := c.Now().Uncertainty
+		if u < prev || u > 50 {
+			t.Fatalf("after %dms: uncertainty %d (previous %d, cap 50)", wall-1_000, u, prev)
+		}
+		prev = u
+	}
+	if prev != 50 {
+		t.Fatalf("uncertainty plateaued at %d, want the cap", prev)
+	}
+
+	// Synchronizing through Update restarts growth from the drift bound
+	c.Update(Timestamp{Physical: wall}, 0)
+	wall += 100
+	if u := c.Now().Uncertainty; u != 15 {
+		t.Fatalf("after resync: uncertainty %d, want 15", u)
+	}
+
+	if err := (Config{MaxClockDriftMillis: 10, MaxUncertaintyMillis: 5}).Validate(); !errors.Is(err, ErrInvalidConfig) {
+		t.Fatalf("cap below drift: got %v, want ErrInvalidConfig", err)
+	}
+}
+
+// Cross-cluster timestamps need a gap beyond both uncertainties and the skew
+func TestCrossClusterAfter(t *testing.T) {
+	b := Timestamp{Physical: 1_000, Uncertainty: 5}
+
+	// Within one cluster the logical counter orders equal physical times
+	same := Timestamp{Physical: 1_000, Logical: 1, Uncertainty: 5}
+	if !DefinitelyAfter(same, b) {
+		t.Fatalf("same-cluster successor not ordered")
+	}
+	if CrossClusterAfter(same, b, 0) {
+		t.Fatalf("logical counter ordered timestamps across clusters")
+	}
+
+	const skew = 20
+	for _, c := range []struct {
+		gap  int64
+		want bool
+	}{
+		{5, false},
+		{10, false},
+		{30, false},
+		{31, true},
+		{100, true},
+	} {
+		a := Timestamp{Physical: b.Physical + c.gap, Uncertainty: 5}
+		if got := CrossClusterAfter(a, b, skew); got != c.want {
+			t.Fatalf("gap %dms: CrossClusterAfter = %v, want %v", c.gap, got, c.want)
+		}
+		if CrossClusterAfter(b, a, skew) {
+			t.Fatalf("gap %dms: ordered backwards", c.gap)
+		}
+	}
+
+	extreme := Timestamp{Physical: math.MaxInt64, Uncertainty: math.MaxInt64}
+	if CrossClusterAfter(extreme, extreme, math.MaxInt64) {
+		t.Fatalf("saturation failure")
+	}
+}
+
+// The ordering helpers agree with DefinitelyAfter and IsConcurrent is symmetric
+func TestOrderingHelpers(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 5_000; i++ {
+		a := Timestamp{Physical: 1_000 + rng.Int63n(20), Logical: uint16(rng.Intn(3)), Uncertainty: rng.Int63n(5)}
+		b := Timestamp{Physical: 1_000 + rng.Int63n(20), Logical: uint16(rng.Intn(3)), Uncertainty: rng.Int63n(5)}
+		if DefinitelyBefore(a, b) != DefinitelyAfter(b, a) {
+			t.Fatalf("DefinitelyBefore(%v, %v) disagrees with DefinitelyAfter", a, b)
+		}
+		if IsConcurrent(a, b) != IsConcurrent(b, a) {
+			t.Fatalf("IsConcurrent(%v, %v) is not symmetric", a, b)
+		}
+		if IsConcurrent(a, b) == (DefinitelyAfter(a, b) || DefinitelyBefore(a, b)) {
+			t.Fatalf("IsConcurrent(%v, %v) = %v contradicts DefinitelyAfter", a, b, IsConcurrent(a, b))
+		}
+		if Compare(a, b) != -Compare(b, a) {
+			t.Fatalf("Compare(%v, %v) is not antisymmetric", a, b)
+		}
+	}
+
+	a := Timestamp{Physical: 1_000, Logical: 1, Uncertainty: 50}
+	b := Timestamp{Physical: 1_010, Uncertainty: 50}
+	if Compare(a, b) != -1 || !IsConcurrent(a, b) {
+		t.Fatalf("Compare must ignore uncertainty: Compare=%d IsConcurrent=%v", Compare(a, b), IsConcurrent(a, b))
+	}
+	if !IsConcurrent(a, a) {
+		t.Fatalf("identical timestamps must be concurrent")
+	}
+}
+
+// Config.NowFunc drives both Now and Update
+func TestConfigNowFunc(t *testing.T) {
+	wall := int64(1_000)
+	c := New(Config{NowFunc: func() int64 { return wall }})
+
+	a, b := c.Now(), c.Now()
+	if a.Physical != 1_000 || a.Logical != 0 || b.Physical != 1_000 || b.Logical != 1 {
+		t.Fatalf("same millisecond: got %v then %v, want 1000.0 then 1000.1", a, b)
+	}
+
+	wall = 900 // backward jump
+	if ts := c.Now(); ts.Physical != 1_000 || ts.Logical != 2 {
+		t.Fatalf("after backward jump got %v, want 1000.2", ts)
+	}
+	if st := c.DebugState(); st.BackwardJumps != 1 {
+		t.Fatalf("BackwardJumps = %d, want 1", st.BackwardJumps)
+	}
+
+	wall = 2_000
+	if ts := c.Update(Timestamp{Physical: 1_500, Logical: 7}, 0); ts.Physical != 2_000 || ts.Logical != 0 {
+		t.Fatalf("Update with local time ahead got %v, want 2000.0", ts)
+	}
+}
+
+// Commit-wait covers both the timestamp's and the local reading's uncertainty
+func TestCanCommit(t *testing.T) {
+	wall := int64(1_000)
+	c := New(Config{MaxClockDriftMillis: 5, NowFunc: func() int64 { return wall }})
+	ts := c.Now()
+
+	// Latest possible time of ts is 1005; a local reading is only known to
+	// be past it once now-5 exceeds that.
+	for _, tc := range []struct {
+		wall int64
+		ok   bool
+		wait time.Duration
+	}{
+		{1_000, false, 11 * time.Millisecond},
+		{1_006, false, 5 * time.Millisecond},
+		{1_010, false, time.Millisecond},
+		{1_011, true, 0},
+	} {
+		wall = tc.wall
+		ok, wait := c.CanCommit(ts)
+		if ok != tc.ok || wait != tc.wait {
+			t.Fatalf("wall %d: CanCommit = %v, %v; want %v, %v", tc.wall, ok, wait, tc.ok, tc.wait)
+		}
+	}
+
+	// Gaps too wide for a Duration saturate instead of going negative
+	far := Timestamp{Physical: math.MaxInt64 - 10, Uncertainty: 5}
+	for _, w := range []int64{1_000, math.MinInt64 + 10} {
+		wall = w
+		if ok, wait := c.CanCommit(far); ok || wait != math.MaxInt64 {
+			t.Fatalf("wall %d: CanCommit(far) = %v, %v; want false, max", w, ok, wait)
+		}
+	}
+}
+
+// WaitUntilPast returns once the real clock has left the window
+func TestWaitUntilPast(t *testing.T) {
+	c := New(Config{MaxClockDriftMillis: 2})
+	ts := c.Now()
+	c.WaitUntilPast(ts)
+	if ok, wait := c.CanCommit(ts); !ok {
+		t.Fatalf("CanCommit after WaitUntilPast = false, %v", wait)
+	}
+	if after := c.Now(); !DefinitelyAfter(after, ts) {
+		t.Fatalf("%v taken after commit-wait is not definitely after %v", after, ts)
+	}
+
+	// A frozen clock never leaves the window, so only the context ends it
+	frozenClock := New(Config{MaxClockDriftMillis: 2, NowFunc: func() int64 { return 1_000 }})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := frozenClock.WaitUntilPastContext(ctx, frozenClock.Now()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if err := c.WaitUntilPastContext(context.Background(), ts); err != nil {
+		t.Fatalf("WaitUntilPastContext past the window = %v", err)
+	}
+}
+
+// Uncertainty inflated by a remote timestamp relaxes back to the drift bound
+func TestUncertaintyDecay(t *testing.T) {
+	wall := int64(1_000)
+	c := New(Config{MaxClockDriftMillis: 5, NowFunc: func() int64 { return wall }})
+	c.Update(Timestamp{Physical: 1_000, Uncertainty: 100}, 40)
+
+	for _, tc := range []struct{ now, want int64 }{
+		{1_000, 120},
+		{1_050, 70},
+		{1_114, 6},
+		{1_115, 5},
+		{5_000, 5},
+	} {
+		if got := c.UncertaintyAt(tc.now); got != tc.want {
+			t.Fatalf("UncertaintyAt(%d) = %d, want %d", tc.now, got, tc.want)
+		}
+	}
+	wall = 1_050
+	if got := c.Uncertainty(); got != 70 {
+		t.Fatalf("Uncertainty at 1050 = %d, want 70", got)
+	}
+
+	// A later, tighter message does not revive the decayed inflation
+	wall = 1_100
+	if ts := c.Update(Timestamp{Physical: 1_100, Uncertainty: 2}, 0); ts.Uncertainty != 20 {
+		t.Fatalf("Update after decay carried uncertainty %d, want 20", ts.Uncertainty)
+	}
+}
+
+// Update returns the merged state, which dominates the remote
+func TestUpdateReturnsTimestamp(t *testing.T) {
+	cases := []struct {
+		name   string
+		now    int64
+		remote Timestamp
+	}{
+		{"remote ahead", 1_000, Timestamp{Physical: 2_000, Logical: 4, Uncertainty: 5}},
+		{"local far ahead", 5_000, Timestamp{Physical: 2_000, Logical: 4, Uncertainty: 5}},
+	}
+
+	for _, tc := range cases {
+		c := New(Config{})
+		c.now = frozen(tc.now)
+
+		ts := c.Update(tc.remote, 20)
+		if rel := Relation(ts, tc.remote); rel != After {
+			t.Errorf("%s: result %+v is %v relative to remote", tc.name, ts, rel)
+		}
+
+		c.mu.Lock()
+		state := Timestamp{Physical: c.physical, Logical: c.logical, Uncertainty: c.uncertainty}
+		c.mu.Unlock()
+		if ts != state {
+			t.Errorf("%s: returned %+v, internal state %+v", tc.name, ts, state)
+		}
+	}
+}
+
+// DebugState reflects the clock after Now and Update calls
+func TestDebugState(t *testing.T) {
+	wall := int64(1_000)
+	c := New(Config{MaxClockDriftMillis: 5})
+	c.now = func() int64 { return wall }
+
+	c.Now()
+	c.Now()
+	wall = 900 // backward jump
+	c.Update(Timestamp{Physical: 1_200, Logical: 3, Uncertainty: 10}, 20)
+
+	st := c.DebugState()
+	want := ClockState{
+		Physical:      1_200,
+		Logical:       4,
+		Uncertainty:   20,
+		WallNow:       900,
+		BackwardJumps: 1,
+	}
+	if st != want {
+		t.Fatalf("DebugState = %+v, want %+v", st, want)
+	}
+
+	const s = "hlc=1200.4 ±20ms wall=900 ahead=300ms backward_jumps=1"
+	if got := st.String(); got != s {
+		t.Fatalf("String = %q, want %q", got, s)
+	}
+}
+
+// A barrier waiter is released by an Update that reaches the token
+func TestWaitForBarrier(t *testing.T) {
+	c := New(Config{})
+	c.now = frozen(1_000)
+
+	token := Timestamp{Physical: 5_000, Logical: 2}
+	done := make(chan error, 1)
+	go func() {
+		done <- c.WaitForBarrier(context.Background(), token)
+	}()
+
+	// Local ticks below the token do not release the waiter
+	c.Now()
+	select {
+	case err := <-done:
+		t.Fatalf("released early: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	c.Update(Timestamp{Physical: 5_000, Logical: 1}, 0)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("waiter not released by Update")
+	}
+
+	// Cancellation returns the context error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.WaitForBarrier(ctx, Timestamp{Physical: 9_000}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+// Interval bounds and overlap detection
+func TestIntervalOverlaps(t *testing.T) {
+	a := Timestamp{Physical: 100, Uncertainty: 10}
+	if lo, hi := a.Interval(); lo != 90 || hi != 110 {
+		t.Fatalf("Interval = [%d, %d], want [90, 110]", lo, hi)
+	}
+
+	cases := []struct {
+		name string
+		b    Timestamp
+		want bool
+	}{
+		{"disjoint", Timestamp{Physical: 130, Uncertainty: 5}, false},
+		{"touching", Timestamp{Physical: 115, Uncertainty: 5}, true},
+		{"overlapping", Timestamp{Physical: 105, Uncertainty: 20}, true},
+		{"contained", Timestamp{Physical: 100}, true},
+	}
+	for _, tc := range cases {
+		if got := a.Overlaps(tc.b); got != tc.want {
+			t.Errorf("%s: Overlaps = %v, want %v", tc.name, got, tc.want)
+		}
+		if got := tc.b.Overlaps(a); got != tc.want {
+			t.Errorf("%s: Overlaps not symmetric", tc.name)
+		}
+	}
+}
+
+// Update always dominates both the prior local state and the remote
+func TestUpdateDominatesProperty(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+
+	wall := int64(1_000_000)
+	c := NewDeterministic(func() int64 { return wall })
+
+	for i := 0; i < 100_000; i++ {
+		// Wall clock wanders forwards and occasionally backwards
+		wall += rng.Int63n(20) - 5
+
+		before := c.DebugState()
+		local := Timestamp{Physical: before.Physical, Logical: before.Logical}
+		remote := Timestamp{
+			Physical:    wall + rng.Int63n(200) - 100,
+			Logical:     uint16(rng.Intn(1_000)),
+			Uncertainty: rng.Int63n(50),
+		}
+		rtt := rng.Int63n(100)
+
+		got := c.Update(remote, rtt)
+		if !less(local, got) || !less(remote, got) {
+			t.Fatalf("step %d: Update(%+v, %d) = %+v does not dominate local %+v",
+				i, remote, rtt, got, local)
+		}
+		if got.Uncertainty < remote.Uncertainty+rtt/2 {
+			t.Fatalf("step %d: uncertainty %d below remote bound", i, got.Uncertainty)
+		}
+	}
+}
+
+// The safe timestamp is the quorum-th highest ack
+func TestSafeTimestamp(t *testing.T) {
+	acks := []Timestamp{
+		{Physical: 100, Logical: 0},
+		{Physical: 300, Logical: 1},
+		{Physical: 200, Logical: 5},
+		{Physical: 300, Logical: 0},
+		{Physical: 150, Logical: 2},
+	}
+
+	got, ok := SafeTimestamp(acks, 3)
+	if !ok || got != (Timestamp{Physical: 200, Logical: 5}) {
+		t.Fatalf("SafeTimestamp = %+v, %v; want 200.5", got, ok)
+	}
+
+	atOrAbove := 0
+	for _, a := range acks {
+		if !less(a, got) {
+			atOrAbove++
+		}
+	}
+	if atOrAbove < 3 {
+		t.Fatalf("only %d acks at or above safe point", atOrAbove)
+	}
+
+	if _, ok := SafeTimestamp(acks[:2], 3); ok {
+		t.Fatalf("expected false with fewer acks than quorum")
+	}
+	if acks[0].Physical != 100 {
+		t.Fatalf("input slice was reordered")
+	}
+}
@@ -0,0 +1,137 @@
+package hlc
+
+import (
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// encodedLen is the size of the binary timestamp encoding: Physical and
+// Uncertainty as big-endian int64, Logical as big-endian uint16.
+const encodedLen = 18
+
+// packedPhysicalBits is how many bits of Physical fit beside Logical when a
+// timestamp without uncertainty is packed into a single int64.
+const packedPhysicalBits = 47
+
+// encode returns the 18-byte binary form of t.
+func (t Timestamp) encode() []byte {
+	return t.AppendBinary(make([]byte, 0, encodedLen))
+}
+
+// AppendBinary appends the fixed 18-byte big-endian encoding of t to b and
+// returns the extended slice: Physical as int64, Logical as uint16, then
+// Uncertainty as int64. Encodings of timestamps with non-negative physical
+// time and equal uncertainty sort bytewise in (Physical, Logical) order.
+func (t Timestamp) AppendBinary(b []byte) []byte {
+	b = binary.BigEndian.AppendUint64(b, uint64(t.Physical))
+	b = binary.BigEndian.AppendUint16(b, t.Logical)
+	return binary.BigEndian.AppendUint64(b, uint64(t.Uncertainty))
+}
+
+// DecodeTimestamp decodes a timestamp written by AppendBinary from the
+// start of b and returns it with the number of bytes consumed, so several
+// timestamps can be read back to back from one buffer. A buffer shorter
+// than 18 bytes returns an error wrapping io.ErrShortBuffer.
+func DecodeTimestamp(b []byte) (Timestamp, int, error) {
+	if len(b) < encodedLen {
+		return Timestamp{}, 0, fmt.Errorf("hlc: decoding timestamp from %d bytes, need %d: %w", len(b), encodedLen, io.ErrShortBuffer)
+	}
+	return Timestamp{
+		Physical:    int64(binary.BigEndian.Uint64(b[0:8])),
+		Logical:     binary.BigEndian.Uint16(b[8:10]),
+		Uncertainty: int64(binary.BigEndian.Uint64(b[10:18])),
+	}, encodedLen, nil
+}
+
+// decode parses exactly one 18-byte encoding.
+func decode(b []byte) (Timestamp, error) {
+	if len(b) != encodedLen {
+		return Timestamp{}, fmt.Errorf("hlc: encoded timestamp is %d bytes, want %d", len(b), encodedLen)
+	}
+	ts, _, err := DecodeTimestamp(b)
+	return ts, err
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler using the 18-byte
+// layout: Physical and Uncertainty as big-endian int64 around a big-endian
+// uint16 Logical.
+func (t Timestamp) MarshalBinary() ([]byte, error) {
+	return t.encode(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler for the layout
+// produced by MarshalBinary.
+func (t *Timestamp) UnmarshalBinary(data []byte) error {
+	ts, err := decode(data)
+	if err != nil {
+		return err
+	}
+	*t = ts
+	return nil
+}
+
+// Value implements driver.Valuer. A timestamp without uncertainty whose
+// physical time fits in 47 bits is stored as the int64 Physical<<16|Logical,
+// which keeps (Physical, Logical) order under integer comparison; anything
+// else is stored as the 18-byte binary encoding.
+func (t Timestamp) Value() (driver.Value, error) {
+	if t.Uncertainty == 0 && t.Physical >= 0 && t.Physical < 1<<packedPhysicalBits {
+		return t.Physical<<16 | int64(t.Logical), nil
+	}
+	return t.encode(), nil
+}
+
+// Scan implements sql.Scanner, accepting either form produced by Value.
+// Any other source type, including NULL, is an error.
+func (t *Timestamp) Scan(src any) error {
+	switch v := src.(type) {
+	case int64:
+		if v < 0 {
+			return fmt.Errorf("hlc: packed timestamp %d is negative", v)
+		}
+		*t = Timestamp{Physical: v >> 16, Logical: uint16(v)}
+		return nil
+	case []byte:
+		ts, err := decode(v)
+		if err != nil {
+			return err
+		}
+		*t = ts
+		return nil
+	default:
+		return fmt.Errorf("hlc: cannot scan %T into Timestamp", src)
+	}
+}
+
+// timestampJSON is the JSON form of a Timestamp. Logical is decoded as an
+// int64 so out-of-range values can be rejected rather than silently
+// truncated.
+type timestampJSON struct {
+	Physical    int64 `json:"physical"`
+	Logical     int64 `json:"logical"`
+	Uncertainty int64 `json:"uncertainty"`
+}
+
+// MarshalJSON implements json.Marshaler, encoding t as
+// {"physical":...,"logical":...,"uncertainty":...}.
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(timestampJSON{Physical: t.Physical, Logical: int64(t.Logical), Uncertainty: t.Uncertainty})
+}
+
+// UnmarshalJSON implements json.Unmarshaler for the form produced by
+// MarshalJSON. A logical value outside the uint16 range is an error.
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	var w timestampJSON
+	if err := json.Unmarshal(data, &w); err != nil {
+		return err
+	}
+	if w.Logical < 0 || w.Logical > math.MaxUint16 {
+		return fmt.Errorf("hlc: logical %d out of range", w.Logical)
+	}
+	*t = Timestamp{Physical: w.Physical, Logical: uint16(w.Logical), Uncertainty: w.Uncertainty}
+	return nil
+}
@@ -0,0 +1,142 @@
+package hlc
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"testing"
+)
+
+var (
+	_ driver.Valuer              = Timestamp{}
+	_ sql.Scanner                = (*Timestamp)(nil)
+	_ encoding.BinaryMarshaler   = Timestamp{}
+	_ encoding.BinaryUnmarshaler = (*Timestamp)(nil)
+	_ json.Marshaler             = Timestamp{}
+	_ json.Unmarshaler           = (*Timestamp)(nil)
+)
+
+// Timestamps round-trip through Valuer and Scanner in both encodings
+func TestSQLRoundTrip(t *testing.T) {
+	cases := []struct {
+		ts     Timestamp
+		packed bool
+	}{
+		{Timestamp{}, true},
+		{Timestamp{Physical: 1_700_000_000_000, Logical: 42}, true},
+		{Timestamp{Physical: 1_700_000_000_000, Logical: 65535, Uncertainty: 5}, false},
+		{Timestamp{Physical: 1 << 50, Logical: 1}, false},
+	}
+
+	for _, c := range cases {
+		v, err := c.ts.Value()
+		if err != nil {
+			t.Fatalf("%+v: Value: %v", c.ts, err)
+		}
+		if _, isInt := v.(int64); isInt != c.packed {
+			t.Fatalf("%+v: encoded as %T", c.ts, v)
+		}
+
+		var got Timestamp
+		if err := got.Scan(v); err != nil {
+			t.Fatalf("%+v: Scan: %v", c.ts, err)
+		}
+		if got != c.ts {
+			t.Fatalf("round trip: got %+v, want %+v", got, c.ts)
+		}
+	}
+}
+
+// MarshalBinary round-trips through the 18-byte layout
+func TestMarshalBinary(t *testing.T) {
+	want := Timestamp{Physical: 1_700_000_000_000, Logical: 7, Uncertainty: 12}
+	b, err := want.MarshalBinary()
+	if err != nil || len(b) != 18 {
+		t.Fatalf("MarshalBinary = %x, %v", b, err)
+	}
+
+	var got Timestamp
+	if err := got.UnmarshalBinary(b); err != nil || got != want {
+		t.Fatalf("UnmarshalBinary = %+v, %v; want %+v", got, err, want)
+	}
+	if err := got.UnmarshalBinary(b[:17]); err == nil {
+		t.Fatalf("short input accepted")
+	}
+}
+
+// Timestamps stream back to back through AppendBinary and DecodeTimestamp
+func TestAppendBinary(t *testing.T) {
+	stamps := []Timestamp{
+		{},
+		{Physical: -1, Logical: 7, Uncertainty: -3},
+		{Physical: math.MinInt64, Logical: math.MaxUint16, Uncertainty: math.MaxInt64},
+		{Physical: 1_700_000_000_000, Logical: 42, Uncertainty: 5},
+	}
+	buf := []byte("hdr")
+	for _, ts := range stamps {
+		buf = ts.AppendBinary(buf)
+	}
+	if len(buf) != 3+len(stamps)*encodedLen {
+		t.Fatalf("encoded %d bytes", len(buf))
+	}
+
+	rest := buf[3:]
+	for _, want := range stamps {
+		got, n, err := DecodeTimestamp(rest)
+		if err != nil || n != encodedLen || got != want {
+			t.Fatalf("decoded %+v, %d, %v; want %+v", got, n, err, want)
+		}
+		rest = rest[n:]
+	}
+
+	if _, n, err := DecodeTimestamp(rest); !errors.Is(err, io.ErrShortBuffer) || n != 0 {
+		t.Fatalf("empty buffer: got %d, %v", n, err)
+	}
+	if _, _, err := DecodeTimestamp(buf[3 : 3+encodedLen-1]); !errors.Is(err, io.ErrShortBuffer) {
+		t.Fatalf("short buffer: got %v", err)
+	}
+}
+
+// Timestamps print readably and round-trip through JSON
+func TestStringAndJSON(t *testing.T) {
+	ts := Timestamp{Physical: 1700000000000, Logical: 3, Uncertainty: 5}
+	if got := ts.String(); got != "1700000000000.3±5ms" {
+		t.Fatalf("String = %q", got)
+	}
+
+	data, err := json.Marshal(ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"physical":1700000000000,"logical":3,"uncertainty":5}`; string(data) != want {
+		t.Fatalf("MarshalJSON = %s, want %s", data, want)
+	}
+	var got Timestamp
+	if err := json.Unmarshal(data, &got); err != nil || got != ts {
+		t.Fatalf("round trip: got %+v, %v", got, err)
+	}
+
+	for _, bad := range []string{
+		`{"physical":1,"logical":65536,"uncertainty":0}`,
+		`{"physical":1,"logical":-1,"uncertainty":0}`,
+		`{"physical":"1"}`,
+	} {
+		if err := json.Unmarshal([]byte(bad), &got); err == nil {
+			t.Fatalf("Unmarshal(%s) succeeded: %+v", bad, got)
+		}
+	}
+}
+
+// Scan rejects unexpected source types and malformed blobs
+func TestSQLScanErrors(t *testing.T) {
+	for _, src := range []any{"1700000000000", 3.5, nil, []byte{1, 2, 3}} {
+		var ts Timestamp
+		if err := ts.Scan(src); err == nil {
+			t.Fatalf("Scan(%#v) succeeded: %+v", src, ts)
+		}
+	}
+}
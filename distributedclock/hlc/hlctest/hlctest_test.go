@@ -0,0 +1,56 @@
+package hlctest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/krisalay/distributed-systems-journal/distributedclock/hlc"
+)
+
+// recorder captures failures instead of failing the enclosing test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+// Recorded sequences from a real clock pass; a synthetic regression fails
+func TestAssertMonotonic(t *testing.T) {
+	wall := int64(1_000)
+	c := New(func() int64 { return wall })
+
+	for i := 0; i < 100; i++ {
+		c.Now()
+		if i%10 == 0 {
+			wall++
+		}
+	}
+	c.Update(hlc.Timestamp{Physical: 5_000, Logical: 3}, 0)
+	c.Now()
+
+	if got := len(c.Emitted()); got != 102 {
+		t.Fatalf("recorded %d timestamps, want 102", got)
+	}
+
+	ok := &recorder{TB: t}
+	c.AssertMonotonic(ok)
+	if len(ok.errors) != 0 {
+		t.Fatalf("real clock flagged: %v", ok.errors)
+	}
+
+	// Inject a timestamp that goes backwards
+	c.mu.Lock()
+	c.emitted = append(c.emitted, hlc.Timestamp{Physical: 4_999})
+	c.mu.Unlock()
+
+	bad := &recorder{TB: t}
+	c.AssertMonotonic(bad)
+	if len(bad.errors) != 1 {
+		t.Fatalf("violation reported %d times, want 1: %v", len(bad.errors), bad.errors)
+	}
+}
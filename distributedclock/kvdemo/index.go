@@ -0,0 +1,82 @@
+package kvdemo
+
+import (
+	"sort"
+	"sync"
+)
+
+// index maps terms extracted from live values to the keys holding them.
+type index struct {
+	extract func(Value) string
+
+	mu    sync.Mutex
+	terms map[string]map[string]struct{}
+}
+
+// WithIndex maintains a secondary index named name. extract derives the
+// term a value is indexed under, for example a prefix of its data; each
+// live key is indexed under exactly one term. The index follows accepted
+// writes only and drops keys when they are deleted, so Lookup never returns
+// a tombstoned key.
+func WithIndex(name string, extract func(Value) string) Option {
+	return func(s *Store) {
+		if s.indexes == nil {
+			s.indexes = make(map[string]*index)
+		}
+		s.indexes[name] = &index{extract: extract, terms: make(map[string]map[string]struct{})}
+	}
+}
+
+// Lookup returns the live keys whose value the named index maps to term,
+// in sorted order. An unknown index or term returns nil.
+func (s *Store) Lookup(name, term string) []string {
+	idx := s.indexes[name]
+	if idx == nil {
+		return nil
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	var keys []string
+	for k := range idx.terms[term] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// reindex moves key from the term of old to the term of val in every index.
+// A missing or deleted value has no term. Callers must hold key's stripe
+// lock so updates to one key are applied in order.
+func (s *Store) reindex(key string, old Value, hadOld bool, val Value, hasVal bool) {
+	for _, idx := range s.indexes {
+		idx.mu.Lock()
+		if hadOld && !old.Deleted {
+			idx.remove(idx.extract(old), key)
+		}
+		if hasVal && !val.Deleted {
+			idx.add(idx.extract(val), key)
+		}
+		idx.mu.Unlock()
+	}
+}
+
+// add indexes key under term. Callers must hold idx.mu.
+func (idx *index) add(term, key string) {
+	keys := idx.terms[term]
+	if keys == nil {
+		keys = make(map[string]struct{})
+		idx.terms[term] = keys
+	}
+	keys[key] = struct{}{}
+}
+
+// remove drops key from term. Callers must hold idx.mu.
+func (idx *index) remove(term, key string) {
+	keys := idx.terms[term]
+	delete(keys, key)
+	if len(keys) == 0 {
+		delete(idx.terms, term)
+	}
+}
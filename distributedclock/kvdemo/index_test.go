@@ -0,0 +1,49 @@
+package kvdemo
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/krisalay/distributed-systems-journal/distributedclock/hlc"
+)
+
+// Updates move keys between terms; rejected writes and deletes are honored
+func TestIndexLookup(t *testing.T) {
+	prefix := func(v Value) string {
+		if len(v.Data) == 0 {
+			return ""
+		}
+		return string(v.Data[:1])
+	}
+	s := NewStore(WithIndex("prefix", prefix))
+
+	s.Apply("k1", Value{Data: []byte("apple"), TS: hlc.Timestamp{Physical: 100}})
+	s.Apply("k2", Value{Data: []byte("avocado"), TS: hlc.Timestamp{Physical: 100}})
+	s.Apply("k3", Value{Data: []byte("banana"), TS: hlc.Timestamp{Physical: 100}})
+
+	check := func(term, want string) {
+		t.Helper()
+		if got := fmt.Sprint(s.Lookup("prefix", term)); got != want {
+			t.Fatalf("Lookup(%q) = %s, want %s", term, got, want)
+		}
+	}
+	check("a", "[k1 k2]")
+	check("b", "[k3]")
+
+	// k1 moves from "a" to "b"
+	s.Apply("k1", Value{Data: []byte("blueberry"), TS: hlc.Timestamp{Physical: 200}})
+	check("a", "[k2]")
+	check("b", "[k1 k3]")
+
+	// A stale write must not move it back
+	s.Apply("k1", Value{Data: []byte("apricot"), TS: hlc.Timestamp{Physical: 150}})
+	check("a", "[k2]")
+	check("b", "[k1 k3]")
+
+	s.Delete("k2", hlc.Timestamp{Physical: 300})
+	check("a", "[]")
+
+	if got := s.Lookup("missing", "a"); got != nil {
+		t.Fatalf("unknown index returned %v", got)
+	}
+}
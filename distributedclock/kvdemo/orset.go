@@ -0,0 +1,160 @@
+package kvdemo
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// ORSet is an observed-remove set CRDT.
+//
+// Every Add is identified by a unique tag, such as a replica ID plus a
+// counter. Remove deletes only the tags the replica has observed, so when
+// an add and a remove of the same element race on different replicas the
+// element survives the merge (add wins): the remove could not have seen the
+// concurrent add's tag. Merge is commutative, associative and idempotent,
+// which lets replicas exchange state in any order and converge.
+//
+// The zero value is not usable; create sets with NewORSet or
+// UnmarshalBinary.
+type ORSet struct {
+	// adds maps each element to its live tags
+	adds map[string]map[string]struct{}
+
+	// removed holds every tag that has been removed, so merges cannot
+	// resurrect it
+	removed map[string]struct{}
+}
+
+// NewORSet returns an empty set.
+func NewORSet() *ORSet {
+	return &ORSet{
+		adds:    make(map[string]map[string]struct{}),
+		removed: make(map[string]struct{}),
+	}
+}
+
+// Add inserts elem under tag. Tags must be unique across all replicas and
+// adds; reusing a removed tag has no effect.
+func (s *ORSet) Add(elem, tag string) {
+	if _, gone := s.removed[tag]; gone {
+		return
+	}
+	tags := s.adds[elem]
+	if tags == nil {
+		tags = make(map[string]struct{})
+		s.adds[elem] = tags
+	}
+	tags[tag] = struct{}{}
+}
+
+// Remove deletes elem by retiring every tag this replica has observed for
+// it. Adds with tags not yet observed are unaffected.
+func (s *ORSet) Remove(elem string) {
+	for tag := range s.adds[elem] {
+		s.removed[tag] = struct{}{}
+	}
+	delete(s.adds, elem)
+}
+
+// Contains reports whether elem has at least one live tag.
+func (s *ORSet) Contains(elem string) bool {
+	return len(s.adds[elem]) > 0
+}
+
+// Elements returns the members of the set in sorted order.
+func (s *ORSet) Elements() []string {
+	out := make([]string, 0, len(s.adds))
+	for elem := range s.adds {
+		out = append(out, elem)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Merge folds other into s: the result holds every tag added on either side
+// that neither side has removed.
+func (s *ORSet) Merge(other *ORSet) {
+	for tag := range other.removed {
+		s.removed[tag] = struct{}{}
+	}
+	for elem, tags := range other.adds {
+		for tag := range tags {
+			s.Add(elem, tag)
+		}
+	}
+	for elem, tags := range s.adds {
+		for tag := range tags {
+			if _, gone := s.removed[tag]; gone {
+				delete(tags, tag)
+			}
+		}
+		if len(tags) == 0 {
+			delete(s.adds, elem)
+		}
+	}
+}
+
+// orsetWire is the serialized form of an ORSet. Tags are sorted so equal
+// sets encode to identical bytes.
+type orsetWire struct {
+	Adds    map[string][]string `json:"adds"`
+	Removed []string            `json:"removed"`
+}
+
+// MarshalBinary encodes the set for storage in Value.Data.
+func (s *ORSet) MarshalBinary() ([]byte, error) {
+	w := orsetWire{Adds: make(map[string][]string, len(s.adds)), Removed: sortedTags(s.removed)}
+	for elem, tags := range s.adds {
+		w.Adds[elem] = sortedTags(tags)
+	}
+	return json.Marshal(w)
+}
+
+// UnmarshalBinary replaces the contents of s with the decoded set.
+func (s *ORSet) UnmarshalBinary(data []byte) error {
+	var w orsetWire
+	if err := json.Unmarshal(data, &w); err != nil {
+		return err
+	}
+	*s = *NewORSet()
+	for _, tag := range w.Removed {
+		s.removed[tag] = struct{}{}
+	}
+	for elem, tags := range w.Adds {
+		for _, tag := range tags {
+			s.Add(elem, tag)
+		}
+	}
+	return nil
+}
+
+// sortedTags returns the keys of tags in sorted order.
+func sortedTags(tags map[string]struct{}) []string {
+	out := make([]string, 0, len(tags))
+	for tag := range tags {
+		out = append(out, tag)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ORSetResolver merges two concurrent values whose Data holds encoded
+// ORSets. Use it with WithResolver to store sets that converge instead of
+// losing one side of a concurrent update. The merged value keeps incoming's
+// metadata.
+func ORSetResolver(existing, incoming Value) (Value, error) {
+	merged, other := NewORSet(), NewORSet()
+	if err := merged.UnmarshalBinary(existing.Data); err != nil {
+		return Value{}, err
+	}
+	if err := other.UnmarshalBinary(incoming.Data); err != nil {
+		return Value{}, err
+	}
+	merged.Merge(other)
+
+	data, err := merged.MarshalBinary()
+	if err != nil {
+		return Value{}, err
+	}
+	return Value{Data: data, Meta: incoming.Meta}, nil
+}
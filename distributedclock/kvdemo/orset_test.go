@@ -0,0 +1,103 @@
+package kvdemo
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/krisalay/distributed-systems-journal/distributedclock/hlc"
+)
+
+// encodeSet builds an ORSet value for tests.
+func encodeSet(t *testing.T, s *ORSet, ts hlc.Timestamp) Value {
+	t.Helper()
+	data, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return Value{Data: data, TS: ts}
+}
+
+// decodeSet reads the ORSet stored under key.
+func decodeSet(t *testing.T, st *Store, key string) *ORSet {
+	t.Helper()
+	s := NewORSet()
+	if err := s.UnmarshalBinary(st.Data()[key].Data); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	return s
+}
+
+// A concurrent add and remove converge with the add winning
+func TestORSetConcurrentAddRemove(t *testing.T) {
+	base := NewORSet()
+	base.Add("x", "a1")
+	base.Add("y", "a2")
+
+	replicaA := NewStore(WithResolver(ORSetResolver))
+	replicaB := NewStore(WithResolver(ORSetResolver))
+	initial := encodeSet(t, base, hlc.Timestamp{Physical: 100, Uncertainty: 5})
+	replicaA.Apply("tags", initial)
+	replicaB.Apply("tags", initial)
+
+	// A removes x while B concurrently re-adds it under a fresh tag and
+	// removes y.
+	onA := decodeSet(t, replicaA, "tags")
+	onA.Remove("x")
+	writeA := encodeSet(t, onA, hlc.Timestamp{Physical: 200, Uncertainty: 5})
+
+	onB := decodeSet(t, replicaB, "tags")
+	onB.Add("x", "b1")
+	onB.Remove("y")
+	writeB := encodeSet(t, onB, hlc.Timestamp{Physical: 202, Uncertainty: 5})
+
+	replicaA.Apply("tags", writeA)
+	replicaB.Apply("tags", writeB)
+
+	// Exchange the concurrent writes; both must be merged, not rejected
+	if err := replicaA.ApplyE("tags", writeB); err != nil {
+		t.Fatalf("replica A rejected concurrent write: %v", err)
+	}
+	if err := replicaB.ApplyE("tags", writeA); err != nil {
+		t.Fatalf("replica B rejected concurrent write: %v", err)
+	}
+
+	gotA, gotB := decodeSet(t, replicaA, "tags"), decodeSet(t, replicaB, "tags")
+	if fmt.Sprint(gotA.Elements()) != "[x]" {
+		t.Fatalf("replica A elements = %v, want [x]", gotA.Elements())
+	}
+	if !bytes.Equal(replicaA.Data()["tags"].Data, replicaB.Data()["tags"].Data) {
+		t.Fatalf("replicas diverged: %v vs %v", gotA.Elements(), gotB.Elements())
+	}
+
+	// The merged entry dominates both writes, so neither can be replayed over it
+	if replicaA.Apply("tags", writeA) || replicaA.Apply("tags", writeB) {
+		t.Fatalf("merged value did not dominate its inputs")
+	}
+}
+
+// Merge is idempotent and commutative
+func TestORSetMerge(t *testing.T) {
+	a, b := NewORSet(), NewORSet()
+	a.Add("x", "a1")
+	b.Add("x", "b1")
+	b.Add("z", "b2")
+	b.Remove("x")
+
+	ab, ba := NewORSet(), NewORSet()
+	ab.Merge(a)
+	ab.Merge(b)
+	ab.Merge(b)
+	ba.Merge(b)
+	ba.Merge(a)
+
+	if fmt.Sprint(ab.Elements()) != "[x z]" || fmt.Sprint(ba.Elements()) != "[x z]" {
+		t.Fatalf("merge results: %v, %v", ab.Elements(), ba.Elements())
+	}
+
+	ab.Remove("x")
+	ab.Merge(a)
+	if ab.Contains("x") {
+		t.Fatalf("merge resurrected a removed add")
+	}
+}
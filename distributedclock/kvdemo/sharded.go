@@ -0,0 +1,132 @@
+package kvdemo
+
+import (
+	"sync"
+
+	"github.com/krisalay/distributed-systems-journal/distributedclock/hlc"
+	"github.com/krisalay/distributed-systems-journal/hashring"
+)
+
+// ShardedStore routes a logical keyspace across one Store per ring node.
+//
+// Get reads from the key's primary; Put and Delete write to the full replica
+// set returned by the ring's Route. When ring membership changes, keys are
+// copied to their new replicas and dropped from stores that no longer own
+// them, reporting each copy to the migration hook.
+type ShardedStore struct {
+	ring    *hashring.HashRing
+	factory func(hashring.Node) *Store
+
+	mu      sync.RWMutex
+	shards  map[hashring.Node]*Store
+	migrate func(key string, from, to hashring.Node)
+}
+
+// NewShardedStore builds a ShardedStore over ring, creating each node's
+// Store with factory on first use. It subscribes to ring.OnChange so later
+// membership changes migrate data automatically.
+func NewShardedStore(ring *hashring.HashRing, factory func(hashring.Node) *Store) *ShardedStore {
+	ss := &ShardedStore{
+		ring:    ring,
+		factory: factory,
+		shards:  make(map[hashring.Node]*Store),
+	}
+	ring.OnChange(ss.onChange)
+	return ss
+}
+
+// OnMigrate registers a hook called for every key copied during a
+// membership change, with the node it was copied from and to.
+func (ss *ShardedStore) OnMigrate(fn func(key string, from, to hashring.Node)) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ss.migrate = fn
+}
+
+// Shard returns the Store backing node n, creating it if needed.
+func (ss *ShardedStore) Shard(n hashring.Node) *Store {
+	ss.mu.RLock()
+	s, ok := ss.shards[n]
+	ss.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.shardLocked(n)
+}
+
+// shardLocked is Shard for callers holding ss.mu.
+func (ss *ShardedStore) shardLocked(n hashring.Node) *Store {
+	s, ok := ss.shards[n]
+	if !ok {
+		s = ss.factory(n)
+		ss.shards[n] = s
+	}
+	return s
+}
+
+// Put writes val to every replica of key.
+func (ss *ShardedStore) Put(key string, val Value) {
+	for _, n := range ss.ring.Route(key) {
+		ss.Shard(n).Apply(key, val)
+	}
+}
+
+// Delete writes a tombstone for key on every replica.
+func (ss *ShardedStore) Delete(key string, ts hlc.Timestamp) {
+	for _, n := range ss.ring.Route(key) {
+		ss.Shard(n).Delete(key, ts)
+	}
+}
+
+// Get reads key from its primary. Tombstoned keys are reported as absent.
+func (ss *ShardedStore) Get(key string) (Value, bool) {
+	n := ss.ring.GetNode(key)
+	if n == "" {
+		return Value{}, false
+	}
+	v, ok := ss.Shard(n).get(key)
+	if !ok || v.Deleted {
+		return Value{}, false
+	}
+	return v, true
+}
+
+// onChange rebalances after a ring membership change. A removed node's
+// shard is drained into the new owners before being discarded.
+func (ss *ShardedStore) onChange(c hashring.Change) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if c.Added {
+		ss.shardLocked(c.Node)
+	}
+	ss.rebalanceLocked()
+	if !c.Added {
+		delete(ss.shards, c.Node)
+	}
+}
+
+// rebalanceLocked copies each entry to its current replica set and drops it
+// from stores outside that set. Callers must hold ss.mu.
+func (ss *ShardedStore) rebalanceLocked() {
+	for from, s := range ss.shards {
+		for key, v := range s.entries() {
+			owned := false
+			for _, to := range ss.ring.Route(key) {
+				if to == from {
+					owned = true
+					continue
+				}
+				if ss.shardLocked(to).applyKey(key, v) == nil && ss.migrate != nil {
+					ss.migrate(key, from, to)
+				}
+			}
+			if !owned {
+				s.drop(key)
+			}
+		}
+	}
+}
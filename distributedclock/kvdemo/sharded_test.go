@@ -0,0 +1,75 @@
+package kvdemo
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/krisalay/distributed-systems-journal/distributedclock/hlc"
+	"github.com/krisalay/distributed-systems-journal/hashring"
+)
+
+// Writes land on the replica set and adding a node migrates affected keys
+func TestShardedStore(t *testing.T) {
+	ring := hashring.NewReplicated([]hashring.Node{"n1", "n2", "n3"}, 2, 0)
+	ss := NewShardedStore(ring, func(hashring.Node) *Store { return NewStore() })
+
+	migrated := make(map[string]hashring.Node)
+	ss.OnMigrate(func(key string, from, to hashring.Node) {
+		migrated[key] = to
+	})
+
+	const N = 500
+	for i := 0; i < N; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		ss.Put(key, Value{Data: []byte(key), TS: hlc.Timestamp{Physical: 100}})
+	}
+
+	for i := 0; i < N; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		for _, n := range ring.Route(key) {
+			if _, ok := ss.Shard(n).get(key); !ok {
+				t.Fatalf("%s missing on replica %s", key, n)
+			}
+		}
+		if v, ok := ss.Get(key); !ok || string(v.Data) != key {
+			t.Fatalf("%s: Get = %q, %v", key, v.Data, ok)
+		}
+	}
+
+	ring.AddNode("n4")
+
+	if len(migrated) == 0 {
+		t.Fatalf("adding a node migrated no keys")
+	}
+	for key, to := range migrated {
+		if to != "n4" {
+			t.Fatalf("%s migrated to %s, expected n4", key, to)
+		}
+	}
+
+	for i := 0; i < N; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		replicas := ring.Route(key)
+		for _, n := range []hashring.Node{"n1", "n2", "n3", "n4"} {
+			_, has := ss.Shard(n).get(key)
+			want := n == replicas[0] || n == replicas[1]
+			if has != want {
+				t.Fatalf("%s on %s: present=%v, want %v (replicas %v)", key, n, has, want, replicas)
+			}
+		}
+		if _, ok := ss.Get(key); !ok {
+			t.Fatalf("%s lost after migration", key)
+		}
+	}
+
+	// Dropped keys take their ops with them, so replay does not revive them
+	for _, n := range []hashring.Node{"n1", "n2", "n3", "n4"} {
+		replay := NewStore()
+		for _, op := range ss.Shard(n).OpsSince(0) {
+			replay.Apply(op.Key, op.Value)
+		}
+		if got, want := len(replay.entries()), len(ss.Shard(n).entries()); got != want {
+			t.Fatalf("replaying %s's ops gave %d keys, want %d", n, got, want)
+		}
+	}
+}
@@ -1,41 +1,594 @@
 package kvdemo
 
 import (
+	"errors"
+	"hash/fnv"
+	"math"
+	"sort"
 	"sync"
+	"sync/atomic"
 
 	"github.com/krisalay/distributed-systems-journal/distributedclock/hlc"
 )
 
+// DefaultStripes is the number of lock stripes a Store uses unless
+// configured otherwise with WithStripes.
+const DefaultStripes = 16
+
+// EntryOverheadBytes approximates the fixed per-entry cost of a stored key:
+// map bucket space, the string and slice headers, and the timestamp.
+const EntryOverheadBytes = 64
+
+// Write rejections reported by ApplyE. A write is accepted only when its
+// timestamp is definitely after the current entry's.
+var (
+	// ErrStaleWrite means the write is definitely before, or identical to,
+	// the version already stored.
+	ErrStaleWrite = errors.New("kvdemo: stale write")
+
+	// ErrConcurrentWrite means the write's uncertainty window overlaps the
+	// stored version's, so neither can be ordered before the other.
+	ErrConcurrentWrite = errors.New("kvdemo: concurrent write")
+)
+
+// Value is a versioned payload. Data is stored as-is, so binary encodings
+// such as protobuf can be written without a string round-trip; the store
+// retains the slice and callers must not modify it after Apply.
+//
+// Meta carries headers such as content type or origin node. It is stored and
+// returned with the winning value but never consulted when resolving
+// conflicts, which depend on TS alone. Like Data, the map is retained and
+// must not be modified after Apply.
+//
+// Deleted marks a tombstone: the key was removed at TS. Tombstones take part
+// in conflict resolution like any other write so that a stale put cannot
+// resurrect a deleted key.
 type Value struct {
-	Data string
-	TS   hlc.Timestamp
+	Data    []byte
+	Meta    map[string]string
+	TS      hlc.Timestamp
+	Deleted bool
+}
+
+// CompareValues orders a and b by timestamp with hlc.Compare, returning
+// -1, 0 or +1. Data, metadata and the tombstone flag are ignored.
+func CompareValues(a, b Value) int {
+	return hlc.Compare(a.TS, b.TS)
 }
 
+// ByTimestamp sorts values oldest first by CompareValues.
+type ByTimestamp []Value
+
+func (v ByTimestamp) Len() int           { return len(v) }
+func (v ByTimestamp) Less(i, j int) bool { return CompareValues(v[i], v[j]) < 0 }
+func (v ByTimestamp) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+
+// Op is a single accepted mutation recorded in the store's changelog.
+//
+// Seq increases by one for every accepted write or delete. Delete operations
+// carry the tombstone (Value.Deleted set, Value.TS the delete timestamp), so
+// replaying ops in Seq order through Apply reproduces the source exactly.
+// A key handed to another store by a ShardedStore takes its ops with it,
+// leaving gaps in Seq.
+type Op struct {
+	Seq   uint64
+	Key   string
+	Value Value
+}
+
+// Store is a last-writer-wins key/value map resolved by HLC timestamps.
+//
+// Keys are spread across lock stripes so that operations on different keys
+// proceed in parallel. Whole-store reads such as Data acquire every stripe
+// in index order, which keeps them consistent and deadlock-free.
 type Store struct {
+	stripes []stripe
+
+	// seq numbers accepted mutations across all stripes
+	seq atomic.Uint64
+
+	// equal, when set, detects writes that would not change the data
+	equal func(a, b Value) bool
+
+	// resolve, when set, merges concurrent writes instead of rejecting them
+	resolve func(existing, incoming Value) (Value, error)
+
+	// indexes holds the secondary indexes configured with WithIndex; it is
+	// fixed after construction
+	indexes map[string]*index
+
+	// wal, when set, logs accepted writes before they become visible
+	wal *wal
+
+	// watchMu guards watchers. Events are sent under a read lock.
+	// watching counts subscriptions so writes skip queueing events when
+	// nobody listens.
+	watchMu  sync.RWMutex
+	watchers map[*watcher]struct{}
+	watching atomic.Int32
+}
+
+// stripe is one independently locked shard of the keyspace. Each stripe
+// keeps the changelog entries for its own keys; OpsSince merges them by Seq.
+type stripe struct {
 	mu   sync.Mutex
 	data map[string]Value
+	ops  []Op
+
+	// pending holds watch events queued under mu and delivered by
+	// unlockAndNotify once mu is released. Each delivery takes a ticket
+	// from queued, also under mu, and waits for delivered to reach it, so
+	// events leave in the order they were accepted.
+	pending   []Event
+	queued    uint64
+	deliverMu sync.Mutex
+	turn      sync.Cond
+	delivered uint64
+}
+
+// Option configures a Store during construction.
+type Option func(*Store)
+
+// WithStripes sets the number of lock stripes. Values below 1 are treated
+// as 1, which serializes every operation behind a single lock.
+func WithStripes(n int) Option {
+	return func(s *Store) {
+		s.stripes = make([]stripe, max(n, 1))
+	}
+}
+
+// WithSuppressNoOps makes accepted writes whose data equals the current
+// live value silent: the stored timestamp is bumped, but no changelog op or
+// watch event is produced. Replicas fed from the changelog keep the older
+// timestamp for such keys, which is harmless because the data is identical.
+func WithSuppressNoOps(equal func(a, b Value) bool) Option {
+	return func(s *Store) {
+		s.equal = equal
+	}
+}
+
+// WithResolver installs a merge function for concurrent writes, such as
+// ORSetResolver. When an incoming write is concurrent with the stored value
+// (neither is a tombstone), resolve combines the two and the result is
+// stored at a timestamp that dominates both, as computed by hlc.Barrier. An
+// error from resolve rejects the write with that error. Stale writes are
+// still rejected.
+func WithResolver(resolve func(existing, incoming Value) (Value, error)) Option {
+	return func(s *Store) {
+		s.resolve = resolve
+	}
+}
+
+func NewStore(opts ...Option) *Store {
+	s := &Store{
+		stripes:  make([]stripe, DefaultStripes),
+		watchers: make(map[*watcher]struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	for i := range s.stripes {
+		s.stripes[i].data = make(map[string]Value)
+		s.stripes[i].turn.L = &s.stripes[i].deliverMu
+	}
+	return s
+}
+
+// stripeFor returns the stripe owning key.
+func (s *Store) stripeFor(key string) *stripe {
+	if len(s.stripes) == 1 {
+		return &s.stripes[0]
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &s.stripes[h.Sum32()%uint32(len(s.stripes))]
+}
+
+// lockAll acquires every stripe in index order.
+func (s *Store) lockAll() {
+	for i := range s.stripes {
+		s.stripes[i].mu.Lock()
+	}
+}
+
+// unlockAll releases every stripe acquired by lockAll.
+func (s *Store) unlockAll() {
+	for i := range s.stripes {
+		s.stripes[i].mu.Unlock()
+	}
+}
+
+// Apply writes val under key and reports whether it was accepted. Use
+// ApplyE to learn why a write was rejected.
+func (s *Store) Apply(key string, val Value) bool {
+	return s.applyKey(key, val) == nil
+}
+
+// ApplyE is like Apply but returns ErrStaleWrite or ErrConcurrentWrite when
+// the write is rejected, and nil when it is accepted. Stores with a resolver
+// or a WAL can also return their errors.
+func (s *Store) ApplyE(key string, val Value) error {
+	return s.applyKey(key, val)
+}
+
+// applyKey locks key's stripe and applies val.
+func (s *Store) applyKey(key string, val Value) error {
+	st := s.stripeFor(key)
+	st.mu.Lock()
+	err := s.apply(st, key, val)
+	s.unlockAndNotify(st)
+	return err
+}
+
+// ApplyWithContext performs the write half of a read-modify-write.
+//
+// observed is the timestamp of the version the client read. The supplied
+// clock is advanced past it before stamping, so the new value is always
+// DefinitelyAfter observed and can never be rejected as stale relative to
+// what the client saw. If merging observed leaves the clock inside observed's
+// uncertainty window, the clock is pushed just beyond it, trading a small
+// forward jump for a guaranteed ordering.
+//
+// The write can still lose to a different version that is concurrent with
+// the new timestamp; the returned bool reports whether it was accepted, and
+// the returned Value carries the timestamp that was used.
+func (s *Store) ApplyWithContext(key string, data []byte, observed hlc.Timestamp, clock *hlc.Clock) (Value, bool) {
+	ts := clock.Update(observed, 0)
+	if !hlc.DefinitelyAfter(ts, observed) {
+		// Interval saturates, so only the final step needs a guard
+		_, latest := observed.Interval()
+		if latest < math.MaxInt64 {
+			latest++
+		}
+		ts = clock.Update(hlc.Timestamp{Physical: latest}, 0)
+	}
+	val := Value{Data: data, TS: ts}
+	return val, s.applyKey(key, val) == nil
+}
+
+// get returns the raw entry for key, including tombstones.
+func (s *Store) get(key string) (Value, bool) {
+	st := s.stripeFor(key)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	v, ok := st.data[key]
+	return v, ok
+}
+
+// drop removes key and its changelog entries without leaving a tombstone,
+// so replaying OpsSince does not bring it back. It is used when a key's
+// ownership moves to another store, not for user-visible deletes.
+func (s *Store) drop(key string) {
+	st := s.stripeFor(key)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if old, ok := st.data[key]; ok {
+		s.reindex(key, old, true, Value{}, false)
+	}
+	delete(st.data, key)
+
+	ops := st.ops[:0]
+	for _, op := range st.ops {
+		if op.Key != key {
+			ops = append(ops, op)
+		}
+	}
+	clear(st.ops[len(ops):])
+	st.ops = ops
+}
+
+// Delete writes a tombstone for key at ts, subject to the same ordering rule
+// as Apply.
+func (s *Store) Delete(key string, ts hlc.Timestamp) {
+	st := s.stripeFor(key)
+	st.mu.Lock()
+	s.apply(st, key, Value{TS: ts, Deleted: true})
+	s.unlockAndNotify(st)
+}
+
+// Pop atomically reads key's live value and deletes it with a tombstone at
+// ts. It reports false, leaving the store unchanged, when the key is absent,
+// already deleted, or ts is not definitely after the current value, so
+// concurrent consumers can never pop the same value twice.
+func (s *Store) Pop(key string, ts hlc.Timestamp) (Value, bool) {
+	st := s.stripeFor(key)
+	st.mu.Lock()
+	defer s.unlockAndNotify(st)
+
+	v, ok := st.data[key]
+	if !ok || v.Deleted {
+		return Value{}, false
+	}
+	if s.apply(st, key, Value{TS: ts, Deleted: true}) != nil {
+		return Value{}, false
+	}
+	return v, true
+}
+
+// apply resolves val against the current entry and records accepted writes
+// in the changelog. Callers must hold st.mu.
+func (s *Store) apply(st *stripe, key string, val Value) error {
+	return s.write(st, key, val, true)
+}
+
+// write implements apply. logged controls whether the write is appended to
+// the WAL, which replay disables. Callers must hold st.mu.
+func (s *Store) write(st *stripe, key string, val Value, logged bool) error {
+	existing, ok := st.data[key]
+	if ok {
+		var err error
+		if val, err = s.admit(existing, val); err != nil {
+			return err
+		}
+	}
+	if logged && s.wal != nil {
+		if err := s.wal.append(key, val); err != nil {
+			return err
+		}
+	}
+	st.data[key] = val
+	s.reindex(key, existing, ok, val, true)
+
+	if ok && s.equal != nil && !existing.Deleted && !val.Deleted && s.equal(existing, val) {
+		return nil
+	}
+	st.ops = append(st.ops, Op{Seq: s.seq.Add(1), Key: key, Value: val})
+
+	ev := Event{Type: EventPut, Key: key, Value: val}
+	if val.Deleted {
+		ev.Type = EventDelete
+	}
+	s.queue(st, ev)
+	return nil
 }
 
-func NewStore() *Store {
-	return &Store{data: make(map[string]Value)}
+// admit applies the last-writer-wins rule: val replaces existing only when
+// its timestamp is definitely after existing's. Concurrent writes are merged
+// when a resolver is configured. It returns the value to store.
+func (s *Store) admit(existing, val Value) (Value, error) {
+	switch hlc.Relation(val.TS, existing.TS) {
+	case hlc.After:
+		return val, nil
+	case hlc.Concurrent:
+		if s.resolve == nil || existing.Deleted || val.Deleted {
+			return Value{}, ErrConcurrentWrite
+		}
+		merged, err := s.resolve(existing, val)
+		if err != nil {
+			return Value{}, err
+		}
+		merged.TS = hlc.Barrier(existing.TS, val.TS)
+		return merged, nil
+	default:
+		return Value{}, ErrStaleWrite
+	}
 }
 
-func (s *Store) Apply(key string, val Value) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// WouldAccept reports whether Apply(key, val) would accept the write right
+// now, without changing the store. The answer can be invalidated by any
+// write to key that lands before the real Apply.
+func (s *Store) WouldAccept(key string, val Value) bool {
+	existing, ok := s.get(key)
+	if !ok {
+		return true
+	}
+	_, err := s.admit(existing, val)
+	return err == nil
+}
 
-	existing, ok := s.data[key]
-	if !ok || hlc.DefinitelyAfter(val.TS, existing.TS) {
-		s.data[key] = val
+// GCTombstones permanently removes tombstones whose timestamp is definitely
+// before the given horizon and returns how many were collected. Each removed
+// key is reported to watchers as an EventGC.
+//
+// Callers should pick a horizon that every replica has already observed;
+// collecting a tombstone earlier lets a delayed stale put resurrect the key.
+func (s *Store) GCTombstones(before hlc.Timestamp) int {
+	collected := 0
+	for i := range s.stripes {
+		st := &s.stripes[i]
+		st.mu.Lock()
+		for k, v := range st.data {
+			if v.Deleted && hlc.Relation(v.TS, before) == hlc.Before {
+				delete(st.data, k)
+				s.queue(st, Event{Type: EventGC, Key: k, Value: v})
+				collected++
+			}
+		}
+		s.unlockAndNotify(st)
 	}
+	return collected
 }
 
+// OpsSince returns the changelog entries with Seq greater than seq, in order.
+// OpsSince(0) returns the full history, which is enough to bootstrap a new
+// replica by applying each op to an empty store.
+//
+// Holding every stripe guarantees that no Seq has been assigned without its
+// op being appended, so the only gaps are ops removed along with a key
+// handed to another store.
+func (s *Store) OpsSince(seq uint64) []Op {
+	s.lockAll()
+	defer s.unlockAll()
+
+	var out []Op
+	for i := range s.stripes {
+		ops := s.stripes[i].ops
+		// Per-stripe logs are already in Seq order
+		j := sort.Search(len(ops), func(j int) bool { return ops[j].Seq > seq })
+		out = append(out, ops[j:]...)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Seq < out[j].Seq })
+	return out
+}
+
+// Data returns a copy of all live entries. Tombstoned keys are omitted.
 func (s *Store) Data() map[string]Value {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.lockAll()
+	defer s.unlockAll()
 	copy := make(map[string]Value)
-	for k, v := range s.data {
-		copy[k] = v
+	for i := range s.stripes {
+		for k, v := range s.stripes[i].data {
+			if v.Deleted {
+				continue
+			}
+			copy[k] = v
+		}
 	}
 	return copy
 }
+
+// Get returns the live value for key and whether it is present, locking
+// only key's stripe. A tombstoned key is reported as absent.
+func (s *Store) Get(key string) (Value, bool) {
+	v, ok := s.get(key)
+	if !ok || v.Deleted {
+		return Value{}, false
+	}
+	return v, true
+}
+
+// Len returns the number of live entries, tombstones excluded.
+func (s *Store) Len() int {
+	s.lockAll()
+	defer s.unlockAll()
+	n := 0
+	for i := range s.stripes {
+		for _, v := range s.stripes[i].data {
+			if !v.Deleted {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Keys returns the keys of all live entries in sorted order, tombstones
+// excluded, without copying their values.
+func (s *Store) Keys() []string {
+	s.lockAll()
+	keys := make([]string, 0)
+	for i := range s.stripes {
+		for k, v := range s.stripes[i].data {
+			if !v.Deleted {
+				keys = append(keys, k)
+			}
+		}
+	}
+	s.unlockAll()
+	sort.Strings(keys)
+	return keys
+}
+
+// DataWithTombstones returns a copy of every entry, including tombstoned
+// keys with Deleted set, for replication and audit tooling that must see
+// deletes. Data is the live-only view.
+func (s *Store) DataWithTombstones() map[string]Value {
+	return s.entries()
+}
+
+// KeyValue is a single live entry returned by SortedEntries.
+type KeyValue struct {
+	Key   string
+	Value Value
+}
+
+// SortedEntries returns the live entries sorted by key, tombstones
+// excluded. Unlike Data the order is deterministic, so two replicas holding
+// the same writes produce identical slices, suitable for digests and
+// anti-entropy comparison.
+func (s *Store) SortedEntries() []KeyValue {
+	data := s.Data()
+	out := make([]KeyValue, 0, len(data))
+	for k, v := range data {
+		out = append(out, KeyValue{Key: k, Value: v})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// ForEachByTime calls fn for every live entry in timestamp order, oldest
+// first, as ordered by CompareValues; entries with identical timestamps are
+// visited in key order. It stops early when fn returns false. The entries
+// are snapshotted before the first call, so fn may write to the store.
+func (s *Store) ForEachByTime(fn func(key string, v Value) bool) {
+	entries := s.SortedEntries()
+	sort.SliceStable(entries, func(i, j int) bool {
+		return CompareValues(entries[i].Value, entries[j].Value) < 0
+	})
+	for _, e := range entries {
+		if !fn(e.Key, e.Value) {
+			return
+		}
+	}
+}
+
+// StoreDiff describes how two stores diverge. Each list is sorted.
+type StoreDiff struct {
+	// OnlyA and OnlyB list keys held by just one of the stores
+	OnlyA, OnlyB []string
+
+	// Conflicting lists keys held by both whose winning timestamps differ
+	Conflicting []string
+}
+
+// Compare reports how a and b diverge, for verifying that anti-entropy has
+// converged. Tombstones count as entries, so a key deleted on one side and
+// never written on the other is reported in OnlyA or OnlyB, and a key live
+// on one side but deleted on the other conflicts. Each store is read in one
+// consistent pass, but the two passes are not atomic with each other.
+func Compare(a, b *Store) StoreDiff {
+	ea, eb := a.entries(), b.entries()
+
+	var d StoreDiff
+	for k, va := range ea {
+		vb, ok := eb[k]
+		switch {
+		case !ok:
+			d.OnlyA = append(d.OnlyA, k)
+		case va.TS != vb.TS:
+			d.Conflicting = append(d.Conflicting, k)
+		}
+	}
+	for k := range eb {
+		if _, ok := ea[k]; !ok {
+			d.OnlyB = append(d.OnlyB, k)
+		}
+	}
+	sort.Strings(d.OnlyA)
+	sort.Strings(d.OnlyB)
+	sort.Strings(d.Conflicting)
+	return d
+}
+
+// entries returns a copy of every entry, including tombstones.
+func (s *Store) entries() map[string]Value {
+	s.lockAll()
+	defer s.unlockAll()
+	out := make(map[string]Value)
+	for i := range s.stripes {
+		for k, v := range s.stripes[i].data {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// ApproxBytes estimates the store's memory footprint as the sum of key
+// lengths, value data and metadata lengths and EntryOverheadBytes per entry,
+// tombstones included. It ignores the changelog and is meant as a compaction
+// signal, not an exact measurement.
+func (s *Store) ApproxBytes() int64 {
+	s.lockAll()
+	defer s.unlockAll()
+
+	var total int64
+	for i := range s.stripes {
+		for k, v := range s.stripes[i].data {
+			total += int64(len(k)+len(v.Data)) + EntryOverheadBytes
+			for mk, mv := range v.Meta {
+				total += int64(len(mk) + len(mv))
+			}
+		}
+	}
+	return total
+}
@@ -0,0 +1,606 @@
+package kvdemo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/krisalay/distributed-systems-journal/distributedclock/hlc"
+)
+
+// Binary payloads are stored verbatim and resolved by timestamp
+func TestApplyBinary(t *testing.T) {
+	s := NewStore()
+
+	older := []byte{0x00, 0xff, 0x10, 0x00}
+	newer := []byte{0x08, 0x96, 0x01}
+
+	s.Apply("k", Value{Data: older, TS: hlc.Timestamp{Physical: 100, Uncertainty: 5}})
+	s.Apply("k", Value{Data: newer, TS: hlc.Timestamp{Physical: 200, Uncertainty: 5}})
+
+	if got := s.Data()["k"].Data; !bytes.Equal(got, newer) {
+		t.Fatalf("expected newer payload, got %x", got)
+	}
+
+	// A stale write must not overwrite the newer value
+	s.Apply("k", Value{Data: older, TS: hlc.Timestamp{Physical: 150, Uncertainty: 5}})
+	if got := s.Data()["k"].Data; !bytes.Equal(got, newer) {
+		t.Fatalf("stale write won: got %x", got)
+	}
+
+	// A write inside the uncertainty window is ambiguous and is not applied
+	s.Apply("k", Value{Data: older, TS: hlc.Timestamp{Physical: 203, Uncertainty: 5}})
+	if got := s.Data()["k"].Data; !bytes.Equal(got, newer) {
+		t.Fatalf("ambiguous write won: got %x", got)
+	}
+}
+
+// ApplyE reports the sentinel matching each rejection cause
+func TestApplyETypedErrors(t *testing.T) {
+	s := NewStore()
+	if err := s.ApplyE("k", Value{Data: []byte("v1"), TS: hlc.Timestamp{Physical: 200, Uncertainty: 5}}); err != nil {
+		t.Fatalf("first write rejected: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		ts   hlc.Timestamp
+		want error
+	}{
+		{"older", hlc.Timestamp{Physical: 100, Uncertainty: 5}, ErrStaleWrite},
+		{"duplicate", hlc.Timestamp{Physical: 200, Uncertainty: 5}, ErrStaleWrite},
+		{"overlapping", hlc.Timestamp{Physical: 203, Uncertainty: 5}, ErrConcurrentWrite},
+		{"newer", hlc.Timestamp{Physical: 300, Uncertainty: 5}, nil},
+	}
+	for _, c := range cases {
+		err := s.ApplyE("k", Value{Data: []byte(c.name), TS: c.ts})
+		if !errors.Is(err, c.want) || (c.want == nil && err != nil) {
+			t.Fatalf("%s: got %v, want %v", c.name, err, c.want)
+		}
+	}
+
+	if s.Apply("k", Value{Data: []byte("old"), TS: hlc.Timestamp{Physical: 150}}) {
+		t.Fatalf("Apply accepted a stale write")
+	}
+}
+
+// WouldAccept predicts Apply without mutating the store
+func TestWouldAccept(t *testing.T) {
+	s := NewStore()
+	s.Apply("k", Value{Data: []byte("v1"), TS: hlc.Timestamp{Physical: 200, Uncertainty: 5}})
+
+	cases := []struct {
+		name string
+		ts   hlc.Timestamp
+	}{
+		{"stale", hlc.Timestamp{Physical: 100, Uncertainty: 5}},
+		{"concurrent", hlc.Timestamp{Physical: 203, Uncertainty: 5}},
+		{"newer", hlc.Timestamp{Physical: 300, Uncertainty: 5}},
+	}
+	for _, c := range cases {
+		val := Value{Data: []byte(c.name), TS: c.ts}
+		before := len(s.OpsSince(0))
+
+		predicted := s.WouldAccept("k", val)
+		if got := len(s.OpsSince(0)); got != before {
+			t.Fatalf("%s: WouldAccept mutated the store", c.name)
+		}
+		if accepted := s.Apply("k", val); accepted != predicted {
+			t.Fatalf("%s: WouldAccept = %v, Apply = %v", c.name, predicted, accepted)
+		}
+	}
+
+	if !s.WouldAccept("fresh", Value{TS: hlc.Timestamp{Physical: 1}}) {
+		t.Fatalf("write to an absent key should be accepted")
+	}
+}
+
+// Metadata rides along with the winning value and never decides conflicts
+func TestValueMeta(t *testing.T) {
+	s := NewStore()
+	s.Apply("k", Value{
+		Data: []byte("v1"),
+		Meta: map[string]string{"content-type": "text/plain", "origin": "A"},
+		TS:   hlc.Timestamp{Physical: 200},
+	})
+
+	got := s.Data()["k"]
+	if got.Meta["content-type"] != "text/plain" || got.Meta["origin"] != "A" {
+		t.Fatalf("metadata lost: %v", got.Meta)
+	}
+
+	// A stale write loses even when its metadata claims to be newer
+	s.Apply("k", Value{
+		Data: []byte("v0"),
+		Meta: map[string]string{"origin": "B", "write-seq": "999"},
+		TS:   hlc.Timestamp{Physical: 100},
+	})
+	if got := s.Data()["k"]; string(got.Data) != "v1" || got.Meta["origin"] != "A" {
+		t.Fatalf("stale write won: %s %v", got.Data, got.Meta)
+	}
+
+	s.Apply("k", Value{Data: []byte("v2"), Meta: map[string]string{"origin": "C"}, TS: hlc.Timestamp{Physical: 300}})
+	if got := s.Data()["k"]; string(got.Data) != "v2" || len(got.Meta) != 1 || got.Meta["origin"] != "C" {
+		t.Fatalf("newer write did not replace metadata: %s %v", got.Data, got.Meta)
+	}
+}
+
+// Deletes hide keys from Data but not from DataWithTombstones
+func TestDataWithTombstones(t *testing.T) {
+	s := NewStore()
+	s.Apply("keep", Value{Data: []byte("v"), TS: hlc.Timestamp{Physical: 100}})
+	s.Apply("gone", Value{Data: []byte("v"), TS: hlc.Timestamp{Physical: 100}})
+	s.Delete("gone", hlc.Timestamp{Physical: 200})
+
+	if _, ok := s.Data()["gone"]; ok {
+		t.Fatalf("Data includes a deleted key")
+	}
+	all := s.DataWithTombstones()
+	if len(all) != 2 || all["keep"].Deleted {
+		t.Fatalf("unexpected entries: %+v", all)
+	}
+	if v, ok := all["gone"]; !ok || !v.Deleted || v.TS.Physical != 200 {
+		t.Fatalf("tombstone missing or wrong: %+v, %v", v, ok)
+	}
+}
+
+// Get, Len and Keys agree with Data and hide tombstones
+func TestGetLenKeys(t *testing.T) {
+	s := NewStore()
+	s.Apply("b", Value{Data: []byte("2"), TS: hlc.Timestamp{Physical: 100}})
+	s.Apply("a", Value{Data: []byte("1"), TS: hlc.Timestamp{Physical: 100}})
+	s.Apply("c", Value{Data: []byte("3"), TS: hlc.Timestamp{Physical: 100}})
+	s.Delete("c", hlc.Timestamp{Physical: 200})
+
+	if v, ok := s.Get("a"); !ok || string(v.Data) != "1" {
+		t.Fatalf("Get(a) = %q, %v", v.Data, ok)
+	}
+	if _, ok := s.Get("c"); ok {
+		t.Fatalf("Get returned tombstoned key")
+	}
+	if _, ok := s.Get("missing"); ok {
+		t.Fatalf("Get returned missing key")
+	}
+	if s.Len() != len(s.Data()) || s.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", s.Len())
+	}
+	if keys := s.Keys(); len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Fatalf("Keys = %v, want [a b]", keys)
+	}
+}
+
+// Stores with the same writes list identical sorted entries
+func TestSortedEntries(t *testing.T) {
+	type write struct {
+		key string
+		val Value
+	}
+	var writes []write
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("key-%02d", i%20)
+		writes = append(writes, write{key, Value{Data: []byte(fmt.Sprint(i)), TS: hlc.Timestamp{Physical: int64(100 + i)}}})
+	}
+	writes = append(writes, write{"key-03", Value{TS: hlc.Timestamp{Physical: 1_000}, Deleted: true}})
+
+	forward, backward := NewStore(), NewStore()
+	for _, w := range writes {
+		forward.Apply(w.key, w.val)
+	}
+	for i := len(writes) - 1; i >= 0; i-- {
+		backward.Apply(writes[i].key, writes[i].val)
+	}
+
+	got := forward.SortedEntries()
+	if !reflect.DeepEqual(got, backward.SortedEntries()) {
+		t.Fatalf("entries differ by application order")
+	}
+	if len(got) != 19 {
+		t.Fatalf("got %d entries, want 19 live keys", len(got))
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i-1].Key >= got[i].Key {
+			t.Fatalf("entries not sorted at %d: %s, %s", i, got[i-1].Key, got[i].Key)
+		}
+	}
+}
+
+// ForEachByTime visits live entries oldest first, breaking ties by logical
+func TestForEachByTime(t *testing.T) {
+	s := NewStore()
+	s.Apply("c", Value{TS: hlc.Timestamp{Physical: 100, Logical: 2}})
+	s.Apply("a", Value{TS: hlc.Timestamp{Physical: 200}})
+	s.Apply("b", Value{TS: hlc.Timestamp{Physical: 100, Logical: 1}})
+	s.Apply("d", Value{TS: hlc.Timestamp{Physical: 50, Logical: 9}})
+	s.Apply("e", Value{TS: hlc.Timestamp{Physical: 150}})
+	s.Delete("e", hlc.Timestamp{Physical: 300})
+
+	var got []string
+	s.ForEachByTime(func(key string, v Value) bool {
+		got = append(got, key)
+		return true
+	})
+	if want := []string{"d", "b", "c", "a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("visited %v, want %v", got, want)
+	}
+
+	got = got[:0]
+	s.ForEachByTime(func(key string, v Value) bool {
+		got = append(got, key)
+		return len(got) < 2
+	})
+	if len(got) != 2 {
+		t.Fatalf("iteration did not stop early: %v", got)
+	}
+}
+
+// ByTimestamp sorts shuffled values into causal order
+func TestByTimestamp(t *testing.T) {
+	var want []Value
+	for p := int64(1); p <= 5; p++ {
+		for l := uint16(0); l < 3; l++ {
+			want = append(want, Value{Data: []byte(fmt.Sprint(p, l)), TS: hlc.Timestamp{Physical: p, Logical: l}})
+		}
+	}
+	got := append([]Value(nil), want...)
+	rand.New(rand.NewSource(1)).Shuffle(len(got), func(i, j int) { got[i], got[j] = got[j], got[i] })
+
+	sort.Sort(ByTimestamp(got))
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("sorted order wrong: %v", got)
+	}
+	if CompareValues(want[0], Value{TS: want[0].TS, Deleted: true}) != 0 {
+		t.Fatalf("CompareValues looks beyond the timestamp")
+	}
+}
+
+// Compare sorts each divergent key into the right category
+func TestCompare(t *testing.T) {
+	a, b := NewStore(), NewStore()
+	ts := func(p int64) hlc.Timestamp { return hlc.Timestamp{Physical: p} }
+
+	for _, s := range []*Store{a, b} {
+		s.Apply("same", Value{Data: []byte("v"), TS: ts(100)})
+		s.Apply("stale", Value{Data: []byte("v1"), TS: ts(100)})
+	}
+	a.Apply("stale", Value{Data: []byte("v2"), TS: ts(200)})
+	a.Apply("only-a", Value{Data: []byte("a"), TS: ts(100)})
+	b.Apply("only-b", Value{Data: []byte("b"), TS: ts(100)})
+	b.Delete("same", ts(300))
+
+	d := Compare(a, b)
+	if fmt.Sprint(d.OnlyA) != "[only-a]" || fmt.Sprint(d.OnlyB) != "[only-b]" {
+		t.Fatalf("only: %v / %v", d.OnlyA, d.OnlyB)
+	}
+	if fmt.Sprint(d.Conflicting) != "[same stale]" {
+		t.Fatalf("conflicting: %v", d.Conflicting)
+	}
+
+	if d := Compare(a, a); len(d.OnlyA)+len(d.OnlyB)+len(d.Conflicting) != 0 {
+		t.Fatalf("store differs from itself: %+v", d)
+	}
+}
+
+// Replaying the changelog reproduces live keys and tombstones
+func TestReplayOpsWithDelete(t *testing.T) {
+	src := NewStore()
+	src.Apply("a", Value{Data: []byte("1"), TS: hlc.Timestamp{Physical: 100}})
+	src.Apply("b", Value{Data: []byte("2"), TS: hlc.Timestamp{Physical: 110}})
+	src.Delete("a", hlc.Timestamp{Physical: 200})
+
+	ops := src.OpsSince(0)
+	if len(ops) != 3 || !ops[2].Value.Deleted || ops[2].Value.TS.Physical != 200 {
+		t.Fatalf("unexpected oplog: %+v", ops)
+	}
+
+	dst := NewStore()
+	for _, op := range ops {
+		dst.Apply(op.Key, op.Value)
+	}
+
+	if _, ok := dst.Data()["a"]; ok {
+		t.Fatalf("deleted key resurrected after replay")
+	}
+	if got := string(dst.Data()["b"].Data); got != "2" {
+		t.Fatalf("live key lost after replay: %q", got)
+	}
+	srcAll, dstAll := src.entries(), dst.entries()
+	if len(dstAll) != len(srcAll) {
+		t.Fatalf("entry count mismatch: %d vs %d", len(dstAll), len(srcAll))
+	}
+	for k, v := range srcAll {
+		if got := dstAll[k]; got.Deleted != v.Deleted || got.TS != v.TS {
+			t.Fatalf("%s: replica %+v differs from source %+v", k, got, v)
+		}
+	}
+
+	// A stale put must not resurrect the tombstone on the replica either
+	dst.Apply("a", Value{Data: []byte("old"), TS: hlc.Timestamp{Physical: 150}})
+	if _, ok := dst.Data()["a"]; ok {
+		t.Fatalf("stale put resurrected tombstoned key")
+	}
+
+	if tail := src.OpsSince(2); len(tail) != 1 || tail[0].Seq != 3 {
+		t.Fatalf("OpsSince(2) = %+v", tail)
+	}
+}
+
+// Read-modify-write with causal context does not lose to the read version
+func TestApplyWithContext(t *testing.T) {
+	s := NewStore()
+
+	// V1 was written by a node whose clock runs well ahead of ours
+	v1 := Value{Data: []byte("v1"), TS: hlc.Timestamp{
+		Physical:    time.Now().UnixMilli() + 60_000,
+		Uncertainty: 20,
+	}}
+	s.Apply("k", v1)
+
+	clock := hlc.New(hlc.Config{MaxClockDriftMillis: 5})
+
+	// Without context the local stamp is older than V1 and silently loses
+	s.Apply("k", Value{Data: []byte("v2-naive"), TS: clock.Now()})
+	if got := string(s.Data()["k"].Data); got != "v1" {
+		t.Fatalf("expected naive write to lose, got %q", got)
+	}
+
+	read := s.Data()["k"]
+	v2, ok := s.ApplyWithContext("k", []byte("v2"), read.TS, clock)
+	if !ok {
+		t.Fatalf("write with context rejected")
+	}
+	if !hlc.DefinitelyAfter(v2.TS, read.TS) {
+		t.Fatalf("stamped %+v not definitely after observed %+v", v2.TS, read.TS)
+	}
+	if got := string(s.Data()["k"].Data); got != "v2" {
+		t.Fatalf("expected v2 to win, got %q", got)
+	}
+
+	// A near-max uncertainty pushes the clock to the end of time, not past it
+	wide := hlc.Timestamp{Physical: v2.TS.Physical - 1_000, Uncertainty: math.MaxInt64 - 10}
+	if v3, _ := s.ApplyWithContext("k", []byte("v3"), wide, clock); v3.TS.Physical != math.MaxInt64 {
+		t.Fatalf("stamped %+v, want physical saturated at MaxInt64", v3.TS)
+	}
+}
+
+// ApproxBytes grows roughly in proportion to stored data
+func TestApproxBytes(t *testing.T) {
+	fill := func(size int) int64 {
+		s := NewStore()
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("key-%03d", i)
+			s.Apply(key, Value{Data: make([]byte, size), TS: hlc.Timestamp{Physical: 1}})
+		}
+		return s.ApproxBytes()
+	}
+
+	if got := NewStore().ApproxBytes(); got != 0 {
+		t.Fatalf("empty store: %d bytes", got)
+	}
+
+	small, large := fill(1_000), fill(10_000)
+	t.Logf("ApproxBytes 1KB values: %d, 10KB values: %d", small, large)
+
+	ratio := float64(large) / float64(small)
+	if ratio < 9 || ratio > 10.5 {
+		t.Fatalf("10x larger values changed estimate by %.2fx", ratio)
+	}
+}
+
+// Concurrent pops on one key succeed exactly once
+func TestPopConcurrent(t *testing.T) {
+	for round := 0; round < 100; round++ {
+		s := NewStore()
+		s.Apply("job", Value{Data: []byte("payload"), TS: hlc.Timestamp{Physical: 100}})
+
+		var wg sync.WaitGroup
+		results := make(chan bool, 2)
+		for c := 0; c < 2; c++ {
+			wg.Add(1)
+			go func(c int) {
+				defer wg.Done()
+				v, ok := s.Pop("job", hlc.Timestamp{Physical: 200, Logical: uint16(c)})
+				if ok && string(v.Data) != "payload" {
+					t.Errorf("popped wrong value %q", v.Data)
+				}
+				results <- ok
+			}(c)
+		}
+		wg.Wait()
+		close(results)
+
+		popped := 0
+		for ok := range results {
+			if ok {
+				popped++
+			}
+		}
+		if popped != 1 {
+			t.Fatalf("round %d: %d successful pops, want 1", round, popped)
+		}
+		if _, ok := s.Data()["job"]; ok {
+			t.Fatalf("round %d: key still live after pop", round)
+		}
+	}
+}
+
+// Identical-data writes bump the timestamp without events or ops
+func TestSuppressNoOps(t *testing.T) {
+	s := NewStore(WithSuppressNoOps(func(a, b Value) bool {
+		return bytes.Equal(a.Data, b.Data)
+	}))
+	s.Apply("k", Value{Data: []byte("same"), TS: hlc.Timestamp{Physical: 100}})
+
+	events, cancel := s.Watch(4)
+	defer cancel()
+
+	s.Apply("k", Value{Data: []byte("same"), TS: hlc.Timestamp{Physical: 200}})
+	if got := s.Data()["k"].TS.Physical; got != 200 {
+		t.Fatalf("timestamp not bumped: %d", got)
+	}
+	if ops := s.OpsSince(1); len(ops) != 0 {
+		t.Fatalf("no-op write produced ops: %+v", ops)
+	}
+	select {
+	case ev := <-events:
+		t.Fatalf("no-op write fired event %+v", ev)
+	default:
+	}
+
+	// A real change still notifies
+	s.Apply("k", Value{Data: []byte("new"), TS: hlc.Timestamp{Physical: 300}})
+	if ev := <-events; ev.Type != EventPut || string(ev.Value.Data) != "new" {
+		t.Fatalf("expected put event for new data, got %+v", ev)
+	}
+}
+
+// Concurrent writers on disjoint and shared keys. Run with -race.
+func TestConcurrentStriped(t *testing.T) {
+	s := NewStore(WithStripes(8))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(gid int) {
+			defer wg.Done()
+			for i := 0; i < 1_000; i++ {
+				ts := hlc.Timestamp{Physical: int64(i)}
+				s.Apply(fmt.Sprintf("own-%d-%d", gid, i%50), Value{Data: []byte("v"), TS: ts})
+				s.Apply("shared", Value{Data: []byte("v"), TS: ts})
+				if i%100 == 0 {
+					_ = s.Data()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if got := len(s.Data()); got != 16*50+1 {
+		t.Fatalf("expected %d keys, got %d", 16*50+1, got)
+	}
+	ops := s.OpsSince(0)
+	for i, op := range ops {
+		if op.Seq != uint64(i)+1 {
+			t.Fatalf("oplog sequence gap at %d: %d", i, op.Seq)
+		}
+	}
+}
+
+// GC emits an event per collected tombstone to active watchers
+func TestGCTombstonesEmitsEvents(t *testing.T) {
+	s := NewStore()
+	s.Apply("a", Value{Data: []byte("1"), TS: hlc.Timestamp{Physical: 100}})
+	s.Apply("b", Value{Data: []byte("2"), TS: hlc.Timestamp{Physical: 100}})
+	s.Apply("c", Value{Data: []byte("3"), TS: hlc.Timestamp{Physical: 100}})
+	s.Delete("a", hlc.Timestamp{Physical: 200})
+	s.Delete("b", hlc.Timestamp{Physical: 210})
+	s.Delete("c", hlc.Timestamp{Physical: 900}) // too recent to collect
+
+	events, cancel := s.Watch(8)
+	defer cancel()
+
+	if n := s.GCTombstones(hlc.Timestamp{Physical: 500}); n != 2 {
+		t.Fatalf("expected 2 collected tombstones, got %d", n)
+	}
+
+	got := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		ev := <-events
+		if ev.Type != EventGC {
+			t.Fatalf("expected EventGC, got %v", ev.Type)
+		}
+		got[ev.Key] = true
+	}
+	if !got["a"] || !got["b"] {
+		t.Fatalf("unexpected GC events: %v", got)
+	}
+
+	// Puts and deletes reach the same watcher
+	s.Apply("d", Value{Data: []byte("4"), TS: hlc.Timestamp{Physical: 100}})
+	if ev := <-events; ev.Type != EventPut || ev.Key != "d" {
+		t.Fatalf("expected put event for d, got %+v", ev)
+	}
+	if _, ok := s.entries()["c"]; !ok {
+		t.Fatalf("recent tombstone collected")
+	}
+	cancel()
+	if _, ok := <-events; ok {
+		t.Fatalf("channel not closed after cancel")
+	}
+}
+
+// A watcher can read the store and later writes on its stripe still land
+func TestWatcherReadsStore(t *testing.T) {
+	s := NewStore(WithStripes(1))
+	events, cancel := s.Watch(0)
+	defer cancel()
+
+	seen := make(chan Value, 3)
+	go func() {
+		for ev := range events {
+			v, _ := s.Get(ev.Key)
+			_ = s.Data()
+			seen <- v
+		}
+	}()
+
+	for i, k := range []string{"a", "b", "a"} {
+		s.Apply(k, Value{Data: []byte{byte('0' + i)}, TS: hlc.Timestamp{Physical: int64(100 + i)}})
+	}
+	for i, want := range []string{"0", "1", "2"} {
+		select {
+		case v := <-seen:
+			if string(v.Data) != want {
+				t.Fatalf("event %d: watcher read %q, want %q", i, v.Data, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("watcher blocked reading the store")
+		}
+	}
+}
+
+// ---------------- Benchmarks ----------------
+
+// BenchmarkApplyDisjoint measures parallel Apply throughput on disjoint keys
+// with a single lock versus the default striping.
+func BenchmarkApplyDisjoint(b *testing.B) {
+	keys := make([]string, 4096)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+
+	for _, bc := range []struct {
+		name    string
+		stripes int
+	}{
+		{"single", 1},
+		{"striped", DefaultStripes},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			s := NewStore(WithStripes(bc.stripes))
+			var next sync.Mutex
+			offset := 0
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				next.Lock()
+				base := offset
+				offset += 256
+				next.Unlock()
+
+				i := 0
+				for pb.Next() {
+					key := keys[(base+i%256)%len(keys)]
+					s.Apply(key, Value{Data: []byte("v"), TS: hlc.Timestamp{Physical: int64(i)}})
+					i++
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package kvdemo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+const (
+	// walDeleted flags a tombstone record.
+	walDeleted = 1
+
+	// walTimestampLen is the size of hlc.Timestamp's binary encoding.
+	walTimestampLen = 18
+
+	// walMaxField bounds every length and count in a record, so a corrupt
+	// length prefix is reported instead of allocating gigabytes.
+	walMaxField = 64 << 20
+)
+
+// wal appends accepted writes to an io.Writer. Records are written whole by
+// a single Write call so a reader never sees interleaved records.
+type wal struct {
+	mu   sync.Mutex
+	w    io.Writer
+	sync func() error
+	buf  []byte
+}
+
+// WithWAL logs every accepted write and delete to w before it becomes
+// visible, so an acknowledged write survives a restart once ReplayWAL
+// rebuilds the store from the log.
+//
+// If sync is non-nil it is called after each record; it is the durability
+// boundary, for example (*os.File).Sync, and may batch internally. A failed
+// write or sync rejects the operation with the error, leaving the store
+// unchanged; as with any write in flight during a crash, the record may
+// still reach the log and be applied on replay.
+//
+// Each record is the key, a flags byte, the 18-byte hlc binary timestamp,
+// the data and the metadata, with strings and byte slices uvarint
+// length-prefixed.
+func WithWAL(w io.Writer, sync func() error) Option {
+	return func(s *Store) {
+		s.wal = &wal{w: w, sync: sync}
+	}
+}
+
+// append writes a record for key and val.
+func (l *wal) append(key string, val Value) error {
+	if err := checkWALFields(key, val); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := binary.AppendUvarint(l.buf[:0], uint64(len(key)))
+	b = append(b, key...)
+	var flags byte
+	if val.Deleted {
+		flags |= walDeleted
+	}
+	b = append(b, flags)
+	b = val.TS.AppendBinary(b)
+	b = binary.AppendUvarint(b, uint64(len(val.Data)))
+	b = append(b, val.Data...)
+
+	metaKeys := make([]string, 0, len(val.Meta))
+	for k := range val.Meta {
+		metaKeys = append(metaKeys, k)
+	}
+	sort.Strings(metaKeys)
+	b = binary.AppendUvarint(b, uint64(len(metaKeys)))
+	for _, k := range metaKeys {
+		b = binary.AppendUvarint(b, uint64(len(k)))
+		b = append(b, k...)
+		b = binary.AppendUvarint(b, uint64(len(val.Meta[k])))
+		b = append(b, val.Meta[k]...)
+	}
+	l.buf = b
+
+	if _, err := l.w.Write(b); err != nil {
+		return fmt.Errorf("kvdemo: wal write: %w", err)
+	}
+	if l.sync != nil {
+		if err := l.sync(); err != nil {
+			return fmt.Errorf("kvdemo: wal sync: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkWALFields rejects a record that ReplayWAL would refuse to read back.
+func checkWALFields(key string, val Value) error {
+	longest := max(len(key), len(val.Data), len(val.Meta))
+	for k, v := range val.Meta {
+		longest = max(longest, len(k), len(v))
+	}
+	if longest > walMaxField {
+		return fmt.Errorf("kvdemo: wal record field of %d exceeds limit %d", longest, walMaxField)
+	}
+	return nil
+}
+
+// ReplayWAL applies every record read from r, typically the log written by
+// WithWAL, and returns nil at a clean end of input. Replayed writes go
+// through the normal conflict rules but are not logged again; records the
+// store rejects as stale or concurrent are skipped, so replaying a log
+// twice is harmless. A truncated or malformed record stops the replay with
+// an error, leaving earlier records applied.
+func (s *Store) ReplayWAL(r io.Reader) error {
+	br := bufio.NewReader(r)
+	for {
+		key, val, err := readWALRecord(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		st := s.stripeFor(key)
+		st.mu.Lock()
+		err = s.write(st, key, val, false)
+		s.unlockAndNotify(st)
+		if err != nil && !errors.Is(err, ErrStaleWrite) && !errors.Is(err, ErrConcurrentWrite) {
+			return err
+		}
+	}
+}
+
+// readWALRecord decodes one record. It returns io.EOF only when r is
+// exhausted at a record boundary.
+func readWALRecord(r *bufio.Reader) (string, Value, error) {
+	key, err := readWALBytes(r)
+	if err != nil {
+		if err == io.EOF {
+			return "", Value{}, io.EOF
+		}
+		return "", Value{}, walCorrupt(err)
+	}
+
+	var val Value
+	flags, err := r.ReadByte()
+	if err != nil {
+		return "", Value{}, walCorrupt(err)
+	}
+	val.Deleted = flags&walDeleted != 0
+
+	ts := make([]byte, walTimestampLen)
+	if _, err := io.ReadFull(r, ts); err != nil {
+		return "", Value{}, walCorrupt(err)
+	}
+	if err := val.TS.UnmarshalBinary(ts); err != nil {
+		return "", Value{}, walCorrupt(err)
+	}
+
+	if val.Data, err = readWALBytes(r); err != nil {
+		return "", Value{}, walCorrupt(err)
+	}
+
+	n, err := readWALLength(r)
+	if err != nil {
+		return "", Value{}, walCorrupt(err)
+	}
+	if n > 0 {
+		val.Meta = make(map[string]string, min(n, 64)) // n is untrusted until read
+	}
+	for i := uint64(0); i < n; i++ {
+		k, err := readWALBytes(r)
+		if err != nil {
+			return "", Value{}, walCorrupt(err)
+		}
+		v, err := readWALBytes(r)
+		if err != nil {
+			return "", Value{}, walCorrupt(err)
+		}
+		val.Meta[string(k)] = string(v)
+	}
+	return string(key), val, nil
+}
+
+// readWALBytes reads a uvarint length-prefixed byte string.
+func readWALBytes(r *bufio.Reader) ([]byte, error) {
+	n, err := readWALLength(r)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// readWALLength reads a uvarint length or count, rejecting values above
+// walMaxField.
+func readWALLength(r *bufio.Reader) (uint64, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, err
+	}
+	if n > walMaxField {
+		return 0, fmt.Errorf("length %d exceeds limit %d", n, walMaxField)
+	}
+	return n, nil
+}
+
+// walCorrupt reports a record that ends early or cannot be decoded.
+func walCorrupt(err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("kvdemo: corrupt wal record: %w", err)
+}
@@ -0,0 +1,90 @@
+package kvdemo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/krisalay/distributed-systems-journal/distributedclock/hlc"
+)
+
+// Replaying the WAL into a fresh store reproduces the original entries
+func TestWALReplay(t *testing.T) {
+	var log bytes.Buffer
+	syncs := 0
+	src := NewStore(WithWAL(&log, func() error { syncs++; return nil }))
+
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("key-%02d", i%10)
+		src.Apply(key, Value{
+			Data: []byte(fmt.Sprint(i)),
+			Meta: map[string]string{"origin": "A"},
+			TS:   hlc.Timestamp{Physical: int64(100 + i), Uncertainty: 2},
+		})
+	}
+	src.Apply("key-00", Value{Data: []byte("stale"), TS: hlc.Timestamp{Physical: 1}})
+	src.Delete("key-05", hlc.Timestamp{Physical: 1_000})
+
+	if syncs != 31 {
+		t.Fatalf("sync called %d times, want once per accepted write (31)", syncs)
+	}
+
+	walBytes := append([]byte(nil), log.Bytes()...)
+	dst := NewStore()
+	if err := dst.ReplayWAL(bytes.NewReader(walBytes)); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if !reflect.DeepEqual(dst.SortedEntries(), src.SortedEntries()) {
+		t.Fatalf("replayed entries differ")
+	}
+	if d := Compare(src, dst); len(d.OnlyA)+len(d.OnlyB)+len(d.Conflicting) != 0 {
+		t.Fatalf("replayed store diverges: %+v", d)
+	}
+
+	// Replaying again is a no-op, and a torn tail is reported
+	if err := dst.ReplayWAL(bytes.NewReader(walBytes)); err != nil {
+		t.Fatalf("second replay: %v", err)
+	}
+	if err := NewStore().ReplayWAL(bytes.NewReader(walBytes[:len(walBytes)-3])); err == nil {
+		t.Fatalf("truncated log replayed without error")
+	}
+}
+
+// A corrupt length prefix is an error, not a huge allocation
+func TestWALCorruptLength(t *testing.T) {
+	var log bytes.Buffer
+	src := NewStore(WithWAL(&log, nil))
+	src.Apply("k", Value{Data: []byte("v"), TS: hlc.Timestamp{Physical: 1}})
+
+	// The record starts with the key length; claim an exabyte-sized key
+	rec := log.Bytes()
+	corrupt := binary.AppendUvarint(nil, 1<<60)
+	corrupt = append(corrupt, rec[1:]...)
+	if err := NewStore().ReplayWAL(bytes.NewReader(corrupt)); err == nil {
+		t.Fatalf("corrupt key length replayed without error")
+	}
+
+	// A length within the limit but past the end of input is truncation
+	short := append(binary.AppendUvarint(nil, 1_000), rec[1:]...)
+	if err := NewStore().ReplayWAL(bytes.NewReader(short)); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("overlong key length: got %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+// A failing sync rejects the write
+func TestWALSyncError(t *testing.T) {
+	boom := errors.New("disk full")
+	var log bytes.Buffer
+	s := NewStore(WithWAL(&log, func() error { return boom }))
+
+	if err := s.ApplyE("k", Value{Data: []byte("v"), TS: hlc.Timestamp{Physical: 1}}); !errors.Is(err, boom) {
+		t.Fatalf("ApplyE = %v, want sync error", err)
+	}
+	if _, ok := s.Data()["k"]; ok {
+		t.Fatalf("write visible despite failed sync")
+	}
+}
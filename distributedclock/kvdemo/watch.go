@@ -0,0 +1,119 @@
+package kvdemo
+
+import "sync"
+
+// EventType identifies what happened to a key.
+type EventType int
+
+const (
+	// EventPut reports an accepted write.
+	EventPut EventType = iota
+	// EventDelete reports an accepted tombstone.
+	EventDelete
+	// EventGC reports a tombstone removed by GCTombstones.
+	EventGC
+)
+
+// Event is a change notification delivered to watchers. Value is the value
+// that was written, or the tombstone that was deleted or collected.
+type Event struct {
+	Type  EventType
+	Key   string
+	Value Value
+}
+
+// watcher is a single subscription.
+type watcher struct {
+	ch   chan Event
+	done chan struct{}
+	once sync.Once
+}
+
+// Watch subscribes to store changes. Events are delivered in the order they
+// were accepted for any single key; ordering across keys in different
+// stripes is not defined.
+//
+// Events are delivered after the writer releases its stripe lock, so a
+// watcher may read the store, and other writers proceed while an event is
+// in flight. Delivery is still synchronous: a full channel blocks the
+// writer, and later deliveries from the same stripe, until the watcher
+// reads or cancels, so buffer should absorb expected bursts. The returned
+// cancel function unsubscribes and closes the channel; it is safe to call
+// more than once.
+func (s *Store) Watch(buffer int) (<-chan Event, func()) {
+	w := &watcher{
+		ch:   make(chan Event, buffer),
+		done: make(chan struct{}),
+	}
+
+	s.watchMu.Lock()
+	s.watchers[w] = struct{}{}
+	s.watching.Add(1)
+	s.watchMu.Unlock()
+
+	cancel := func() {
+		w.once.Do(func() {
+			// Release any writer blocked on this watcher before taking the
+			// write lock, which waits for in-flight sends.
+			close(w.done)
+
+			s.watchMu.Lock()
+			delete(s.watchers, w)
+			s.watching.Add(-1)
+			close(w.ch)
+			s.watchMu.Unlock()
+		})
+	}
+	return w.ch, cancel
+}
+
+// queue records ev for delivery once st is unlocked. Callers must hold
+// st.mu.
+func (s *Store) queue(st *stripe, ev Event) {
+	if s.watching.Load() > 0 {
+		st.pending = append(st.pending, ev)
+	}
+}
+
+// unlockAndNotify releases st.mu and then delivers the events queued under
+// it, after any deliveries from st that were queued earlier.
+func (s *Store) unlockAndNotify(st *stripe) {
+	events := st.pending
+	st.pending = nil
+	ticket := st.queued
+	if len(events) > 0 {
+		st.queued++
+	}
+	st.mu.Unlock()
+	if len(events) == 0 {
+		return
+	}
+
+	st.deliverMu.Lock()
+	for st.delivered != ticket {
+		st.turn.Wait()
+	}
+	st.deliverMu.Unlock()
+
+	for _, ev := range events {
+		s.emit(ev)
+	}
+
+	st.deliverMu.Lock()
+	st.delivered++
+	st.turn.Broadcast()
+	st.deliverMu.Unlock()
+}
+
+// emit delivers ev to every watcher.
+func (s *Store) emit(ev Event) {
+	s.watchMu.RLock()
+	defer s.watchMu.RUnlock()
+
+	for w := range s.watchers {
+		select {
+		case w.ch <- ev:
+		case <-w.done:
+		}
+	}
+}
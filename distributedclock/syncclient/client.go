@@ -2,13 +2,61 @@ package syncclient
 
 import "github.com/krisalay/distributed-systems-journal/distributedclock/hlc"
 
-// AdjustedTime returns network-corrected server time
+// AdjustedTime returns network-corrected server time, assuming the response
+// spent half the round trip in flight. It is AdjustedTimeAsym with
+// downMillis = rttMillis/2.
 func AdjustedTime(serverTS hlc.Timestamp, rttMillis int64) int64 {
-	return serverTS.Physical + rttMillis/2
+	return AdjustedTimeAsym(serverTS, rttMillis/2)
 }
 
-// TimeLeft computes remaining exam time
+// AdjustedTimeAsym returns network-corrected server time given the measured
+// one-way delay of the response from server to client, for paths where
+// uplink and downlink latency differ too much for halving the RTT.
+func AdjustedTimeAsym(serverTS hlc.Timestamp, downMillis int64) int64 {
+	return serverTS.Physical + downMillis
+}
+
+// TimeLeft computes remaining exam time: the midpoint of TimeLeftBounded,
+// which is the remaining time measured from AdjustedTime.
 func TimeLeft(endTime hlc.Timestamp, serverTS hlc.Timestamp, rttMillis int64) int64 {
-	adjusted := AdjustedTime(serverTS, rttMillis)
-	return endTime.Physical - adjusted
+	lo, hi := TimeLeftBounded(endTime, serverTS, rttMillis)
+	// Round the midpoint up, as AdjustedTime rounds the half-RTT down.
+	return hi - (hi-lo)/2
+}
+
+// TimeLeftBounded returns the smallest and largest plausible remaining exam
+// time. The server clock may be off by ±serverTS.Uncertainty and the response
+// may have spent anywhere from 0 to rttMillis in flight, so the true server
+// time lies in [serverTS.Physical-Uncertainty, serverTS.Physical+rttMillis+Uncertainty].
+// Display min to the candidate so a network hiccup can never cut them off
+// early, and base grace-period decisions on max.
+func TimeLeftBounded(endTime, serverTS hlc.Timestamp, rttMillis int64) (min, max int64) {
+	earliest := serverTS.Physical - serverTS.Uncertainty
+	latest := serverTS.Physical + rttMillis + serverTS.Uncertainty
+	return endTime.Physical - latest, endTime.Physical - earliest
+}
+
+// TimeLeftInterval returns the same window as TimeLeftBounded.
+func TimeLeftInterval(endTime hlc.Timestamp, serverTS hlc.Timestamp, rttMillis int64) (min, max int64) {
+	return TimeLeftBounded(endTime, serverTS, rttMillis)
+}
+
+// Sample is one request/response exchange with the server, timed by the
+// local clock in milliseconds.
+type Sample struct {
+	SentMillis     int64 // Local time the request was sent.
+	ReceivedMillis int64 // Local time the response arrived.
+}
+
+// Delay returns the sample's round-trip time. A local clock that stepped
+// backwards mid-exchange would make it negative, so it is floored at zero.
+func (s Sample) Delay() int64 {
+	return max(s.ReceivedMillis-s.SentMillis, 0)
+}
+
+// ApplyToClock feeds serverTS, as observed through sample, into c as a
+// remote timestamp, so the clock's physical time follows the corrected
+// server time and its uncertainty grows by half the sample delay.
+func ApplyToClock(c *hlc.Clock, serverTS hlc.Timestamp, sample Sample) hlc.Timestamp {
+	return c.Update(serverTS, sample.Delay())
 }
@@ -0,0 +1,83 @@
+package syncclient
+
+import (
+	"testing"
+
+	"github.com/krisalay/distributed-systems-journal/distributedclock/hlc"
+)
+
+// Interval brackets the point estimate and widens with uncertainty and RTT
+func TestTimeLeftInterval(t *testing.T) {
+	end := hlc.Timestamp{Physical: 3_600_000}
+	server := hlc.Timestamp{Physical: 1_000_000, Uncertainty: 5}
+
+	point := TimeLeft(end, server, 40)
+	lo, hi := TimeLeftInterval(end, server, 40)
+	if lo > point || point > hi {
+		t.Fatalf("interval [%d, %d] does not bracket %d", lo, hi, point)
+	}
+
+	wider := server
+	wider.Uncertainty = 50
+	wlo, whi := TimeLeftInterval(end, wider, 40)
+	if wlo >= lo || whi <= hi {
+		t.Fatalf("uncertainty did not widen interval: [%d, %d] vs [%d, %d]", wlo, whi, lo, hi)
+	}
+
+	rlo, rhi := TimeLeftInterval(end, server, 400)
+	if rlo >= lo || rhi-rlo <= hi-lo {
+		t.Fatalf("rtt did not widen interval: [%d, %d] vs [%d, %d]", rlo, rhi, lo, hi)
+	}
+}
+
+// The symmetric AdjustedTime is the asymmetric one with half the RTT
+func TestAdjustedTimeAsym(t *testing.T) {
+	server := hlc.Timestamp{Physical: 1_000_000}
+	if got := AdjustedTimeAsym(server, 30); got != 1_000_030 {
+		t.Fatalf("AdjustedTimeAsym = %d, want 1000030", got)
+	}
+	for _, rtt := range []int64{0, 1, 40, 41} {
+		if AdjustedTime(server, rtt) != AdjustedTimeAsym(server, rtt/2) {
+			t.Fatalf("rtt=%d: AdjustedTime disagrees with AdjustedTimeAsym", rtt)
+		}
+	}
+}
+
+// TimeLeft stays the midpoint of TimeLeftBounded and matches AdjustedTime
+func TestTimeLeftBounded(t *testing.T) {
+	end := hlc.Timestamp{Physical: 3_600_000}
+	for _, u := range []int64{0, 5, 50} {
+		for _, rtt := range []int64{0, 1, 40, 41, 401} {
+			server := hlc.Timestamp{Physical: 1_000_000, Uncertainty: u}
+			lo, hi := TimeLeftBounded(end, server, rtt)
+			got := TimeLeft(end, server, rtt)
+			if want := end.Physical - AdjustedTime(server, rtt); got != want {
+				t.Fatalf("u=%d rtt=%d: TimeLeft = %d, want %d", u, rtt, got, want)
+			}
+			if d := (hi - got) - (got - lo); lo > got || got > hi || d < -1 || d > 1 {
+				t.Fatalf("u=%d rtt=%d: %d is not the midpoint of [%d, %d]", u, rtt, got, lo, hi)
+			}
+		}
+	}
+}
+
+// Applying a server timestamp from the future advances the clock and
+// widens its uncertainty by half the sample delay
+func TestApplyToClock(t *testing.T) {
+	wall := int64(1_000)
+	c := hlc.NewDeterministic(func() int64 { return wall })
+	server := hlc.Timestamp{Physical: 5_000, Uncertainty: 5}
+	sample := Sample{SentMillis: 1_000, ReceivedMillis: 1_080}
+
+	ts := ApplyToClock(c, server, sample)
+	if ts.Physical < server.Physical || c.Now().Physical < server.Physical {
+		t.Fatalf("clock did not advance to server time: %v", ts)
+	}
+	if want := server.Uncertainty + sample.Delay()/2; ts.Uncertainty != want {
+		t.Fatalf("uncertainty %d, want %d", ts.Uncertainty, want)
+	}
+
+	if d := (Sample{SentMillis: 10, ReceivedMillis: 5}).Delay(); d != 0 {
+		t.Fatalf("negative delay not floored: %d", d)
+	}
+}
@@ -0,0 +1,109 @@
+package syncclient
+
+import (
+	"sync"
+
+	"github.com/krisalay/distributed-systems-journal/distributedclock/hlc"
+)
+
+// Estimator combines several exchanges with the server into one NTP-style
+// estimate of the server clock.
+//
+// It keeps the most recent samples in a fixed-size ring buffer and trusts
+// the one with the lowest round-trip time: the less time a response spent
+// in flight, the less room there is for asymmetric delay to skew its
+// offset. Samples whose delay exceeds the configured bound are discarded
+// as outliers.
+//
+// An Estimator is safe for concurrent use.
+type Estimator struct {
+	mu       sync.Mutex
+	samples  []estimate
+	next     int   // ring buffer slot the next sample is written to
+	maxDelay int64 // discard samples slower than this; zero means no bound
+}
+
+// estimate is one retained sample reduced to what the filter needs.
+type estimate struct {
+	offset int64 // server time minus the local midpoint of the exchange
+	delay  int64
+}
+
+// NewEstimator returns an Estimator that keeps the last size samples and
+// discards any sample whose round-trip time exceeds maxDelayMillis. A size
+// below 1 is treated as 1; a maxDelayMillis of zero or less disables the
+// bound.
+func NewEstimator(size int, maxDelayMillis int64) *Estimator {
+	return &Estimator{
+		samples:  make([]estimate, 0, max(size, 1)),
+		maxDelay: max(maxDelayMillis, 0),
+	}
+}
+
+// Add records serverTS as observed through sample, overwriting the oldest
+// retained sample once the buffer is full. It returns false, and records
+// nothing, when the sample's delay exceeds the outlier bound.
+func (e *Estimator) Add(serverTS hlc.Timestamp, sample Sample) bool {
+	delay := sample.Delay()
+	if e.maxDelay > 0 && delay > e.maxDelay {
+		return false
+	}
+	// The server stamped its reply somewhere inside the exchange; assume
+	// the middle, as AdjustedTime does.
+	mid := sample.SentMillis + delay/2
+	est := estimate{offset: serverTS.Physical - mid, delay: delay}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.samples) < cap(e.samples) {
+		e.samples = append(e.samples, est)
+	} else {
+		e.samples[e.next] = est
+	}
+	e.next = (e.next + 1) % cap(e.samples)
+	return true
+}
+
+// Len returns how many samples are currently retained.
+func (e *Estimator) Len() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.samples)
+}
+
+// best returns the retained sample with the lowest delay, preferring the
+// most recent on ties, and false when there are none.
+func (e *Estimator) best() (estimate, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.samples) == 0 {
+		return estimate{}, false
+	}
+	n := len(e.samples)
+	// Walk from newest to oldest so ties keep the freshest sample.
+	b := e.samples[(e.next-1+n)%n]
+	for i := 1; i < n; i++ {
+		if s := e.samples[(e.next-1-i+2*n)%n]; s.delay < b.delay {
+			b = s
+		}
+	}
+	return b, true
+}
+
+// Offset returns the estimated server time minus local time in
+// milliseconds, taken from the lowest-delay retained sample, or zero
+// before any sample has been accepted. It can be passed as an
+// hlc.Config.OffsetProvider so a local clock follows the server.
+func (e *Estimator) Offset() int64 {
+	b, _ := e.best()
+	return b.offset
+}
+
+// AdjustedTime is like the package-level AdjustedTime but corrects
+// serverTS by half the lowest retained round-trip time rather than a
+// single, possibly noisy, sample's. Without samples it returns
+// serverTS.Physical unchanged.
+func (e *Estimator) AdjustedTime(serverTS hlc.Timestamp) int64 {
+	b, _ := e.best()
+	return AdjustedTime(serverTS, b.delay)
+}
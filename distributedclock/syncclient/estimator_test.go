@@ -0,0 +1,51 @@
+package syncclient
+
+import (
+	"testing"
+
+	"github.com/krisalay/distributed-systems-journal/distributedclock/hlc"
+)
+
+// The lowest-delay sample wins and outliers are discarded
+func TestEstimatorMinDelay(t *testing.T) {
+	e := NewEstimator(8, 500)
+	if e.Offset() != 0 || e.AdjustedTime(hlc.Timestamp{Physical: 7}) != 7 {
+		t.Fatalf("empty estimator must not correct")
+	}
+
+	// The server runs 1000ms ahead. Noisy samples spend most of their delay
+	// on the way back, which skews their offset; the fast one does not.
+	e.Add(hlc.Timestamp{Physical: 11_010}, Sample{SentMillis: 10_000, ReceivedMillis: 10_200})
+	e.Add(hlc.Timestamp{Physical: 21_002}, Sample{SentMillis: 20_000, ReceivedMillis: 20_004})
+	e.Add(hlc.Timestamp{Physical: 31_020}, Sample{SentMillis: 30_000, ReceivedMillis: 30_150})
+	if e.Add(hlc.Timestamp{Physical: 41_000}, Sample{SentMillis: 40_000, ReceivedMillis: 45_000}) {
+		t.Fatalf("sample beyond outlier bound accepted")
+	}
+
+	if got := e.Offset(); got != 1_000 {
+		t.Fatalf("Offset = %d, want 1000", got)
+	}
+	if got := e.AdjustedTime(hlc.Timestamp{Physical: 50_000}); got != 50_002 {
+		t.Fatalf("AdjustedTime = %d, want 50002", got)
+	}
+	if e.Len() != 3 {
+		t.Fatalf("Len = %d, want 3", e.Len())
+	}
+}
+
+// Old samples fall out of the ring buffer
+func TestEstimatorRingBuffer(t *testing.T) {
+	e := NewEstimator(3, 0)
+	e.Add(hlc.Timestamp{Physical: 1_500}, Sample{SentMillis: 1_000, ReceivedMillis: 1_001})
+	for i := int64(1); i <= 3; i++ {
+		sent := i * 10_000
+		e.Add(hlc.Timestamp{Physical: sent + 200 + 5}, Sample{SentMillis: sent, ReceivedMillis: sent + 10})
+	}
+	if e.Len() != 3 {
+		t.Fatalf("Len = %d, want 3", e.Len())
+	}
+	// The 1ms sample with offset 500 has been overwritten.
+	if got := e.Offset(); got != 200 {
+		t.Fatalf("Offset = %d, want 200 from the retained samples", got)
+	}
+}
@@ -0,0 +1,297 @@
+package hashring
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// encodingVersion identifies the MarshalBinary layout.
+const encodingVersion = 1
+
+// encodingMagic prefixes every encoded ring.
+var encodingMagic = []byte("HR")
+
+// ErrConfigMismatch is returned by UnmarshalBinary when the encoded ring was
+// built with a different virtual node count, hasher, cluster or vnode
+// placement mode than the receiving ring.
+var ErrConfigMismatch = errors.New("hashring: ring configuration mismatch")
+
+// MarshalBinary snapshots the ring so a restarted process can restore the
+// exact same placement with UnmarshalBinary instead of re-adding nodes and
+// hoping every vnode lands identically.
+//
+// The encoding records the configuration that placement depends on
+// (virtual nodes per weight, hasher name, cluster seed, vnode mode, point
+// width) and, for each node in sorted order, its weight, zone, capacity and
+// placed vnodes. Storing the vnodes rather than re-deriving them makes the
+// restore independent of the order nodes were originally added in.
+func (h *HashRing) MarshalBinary() ([]byte, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	b := append([]byte(nil), encodingMagic...)
+	b = append(b, encodingVersion)
+	b = binary.AppendUvarint(b, uint64(h.virts))
+	b = appendString(b, h.hasherName())
+	b = binary.BigEndian.AppendUint32(b, h.seed)
+	b = append(b, h.modeBits())
+
+	nodes := make([]Node, 0, len(h.nodes))
+	for n := range h.nodes {
+		nodes = append(nodes, n)
+	}
+	sortNodes(nodes)
+
+	b = binary.AppendUvarint(b, uint64(len(nodes)))
+	for _, n := range nodes {
+		b = appendString(b, string(n))
+		b = binary.AppendVarint(b, int64(h.nodes[n]))
+		b = appendString(b, h.zones[n])
+		b = binary.AppendVarint(b, h.capacities[n])
+
+		vns := h.points[n]
+		b = binary.AppendUvarint(b, uint64(len(vns)))
+		for _, v := range vns {
+			b = binary.AppendUvarint(b, uint64(v.index))
+			b = h.appendPoint(b, v.point)
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalBinary replaces the ring's membership and placement with a
+// snapshot produced by MarshalBinary.
+//
+// The receiving ring must be configured like the encoded one: a different
+// virtual node count, hasher, cluster, vnode mode or point width returns an
+// error wrapping ErrConfigMismatch, since lookups would hash keys
+// inconsistently with the restored points. On any error the ring is left
+// unchanged. Listeners see the removal of nodes absent from the snapshot
+// and the addition of every restored node.
+func (h *HashRing) UnmarshalBinary(data []byte) error {
+	h.mu.Lock()
+
+	next, err := h.decode(data)
+	if err != nil {
+		h.mu.Unlock()
+		return err
+	}
+
+	old := &HashRing{ring: h.ring, nodeMap: h.nodeMap}
+	h.gen++
+	for n := range h.nodes {
+		if _, ok := next.nodes[n]; !ok {
+			h.record(Change{Node: n, Added: false, Generation: h.gen})
+		}
+	}
+	restored := make([]Node, 0, len(next.nodes))
+	for n := range next.nodes {
+		restored = append(restored, n)
+	}
+	sortNodes(restored)
+	for _, n := range restored {
+		h.record(Change{Node: n, Added: true, Generation: h.gen})
+	}
+
+	h.nodes, h.zones, h.capacities = next.nodes, next.zones, next.capacities
+	h.points, h.ring, h.nodeMap = next.points, next.ring, next.nodeMap
+	h.recordRebuild(old)
+	h.unlockAndNotify()
+	return nil
+}
+
+// decode parses data into a detached ring holding only membership and
+// placement. Callers must hold at least the read lock.
+func (h *HashRing) decode(data []byte) (*HashRing, error) {
+	r := bytes.NewReader(data)
+	d := decoder{r: r}
+
+	magic := make([]byte, len(encodingMagic))
+	d.read(magic)
+	version := d.byte()
+	if d.err == nil && (!bytes.Equal(magic, encodingMagic) || version != encodingVersion) {
+		return nil, errors.New("hashring: not an encoded ring")
+	}
+
+	virts := int(d.uvarint())
+	hasher := d.string()
+	seed := d.uint32()
+	mode := d.byte()
+	if d.err != nil {
+		return nil, d.corrupt()
+	}
+	switch {
+	case virts != h.virts:
+		return nil, fmt.Errorf("%w: encoded with %d virtual nodes, ring has %d", ErrConfigMismatch, virts, h.virts)
+	case hasher != h.hasherName():
+		return nil, fmt.Errorf("%w: encoded with hasher %q, ring uses %q", ErrConfigMismatch, hasher, h.hasherName())
+	case seed != h.seed || mode != h.modeBits():
+		return nil, fmt.Errorf("%w: cluster, vnode mode or point width differs", ErrConfigMismatch)
+	}
+
+	next := &HashRing{
+		nodes:      make(map[Node]int),
+		zones:      make(map[Node]string),
+		capacities: make(map[Node]int64),
+		points:     make(map[Node][]vnode),
+		nodeMap:    make(map[uint64]Node),
+	}
+	count := d.uvarint()
+	for i := uint64(0); i < count && d.err == nil; i++ {
+		n := Node(d.string())
+		next.nodes[n] = int(d.varint())
+		if zone := d.string(); zone != "" {
+			next.zones[n] = zone
+		}
+		if c := d.varint(); c != 0 {
+			next.capacities[n] = c
+		}
+
+		vcount := d.uvarint()
+		if d.err == nil && vcount > uint64(r.Len()) {
+			return nil, d.corrupt()
+		}
+		var vns []vnode
+		for j := uint64(0); j < vcount && d.err == nil; j++ {
+			v := vnode{index: int(d.uvarint()), point: d.point(h.hasher64 != nil)}
+			if _, dup := next.nodeMap[v.point]; dup && d.err == nil {
+				return nil, fmt.Errorf("hashring: corrupt encoding: point %d placed twice", v.point)
+			}
+			next.nodeMap[v.point] = n
+			next.ring = append(next.ring, v.point)
+			vns = append(vns, v)
+		}
+		if len(vns) > 0 {
+			next.points[n] = vns
+		}
+	}
+	if d.err != nil {
+		return nil, d.corrupt()
+	}
+	if r.Len() != 0 {
+		return nil, errors.New("hashring: corrupt encoding: trailing data")
+	}
+	slices.Sort(next.ring)
+	return next, nil
+}
+
+// hasherName implements HasherName. Callers must hold the read lock.
+func (h *HashRing) hasherName() string {
+	if h.hasher64 != nil {
+		if named, ok := h.hasher64.(interface{ Name() string }); ok {
+			return named.Name()
+		}
+		return fmt.Sprintf("%T", h.hasher64)
+	}
+	if named, ok := h.hasher.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+	return fmt.Sprintf("%T", h.hasher)
+}
+
+// appendPoint appends a ring point as a big-endian integer: 4 bytes, or 8
+// when the ring hashes with a Hasher64.
+func (h *HashRing) appendPoint(b []byte, p uint64) []byte {
+	if h.hasher64 != nil {
+		return binary.BigEndian.AppendUint64(b, p)
+	}
+	return binary.BigEndian.AppendUint32(b, uint32(p))
+}
+
+// appendString appends s with a uvarint length prefix.
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// modeBits packs the placement mode flags: bit 0 for mixed virtual nodes,
+// bit 1 for 64-bit points.
+func (h *HashRing) modeBits() byte {
+	return boolByte(h.mixVnodes) | boolByte(h.hasher64 != nil)<<1
+}
+
+func boolByte(v bool) byte {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// decoder reads encoded fields, remembering the first error so callers
+// can check once after a group of reads.
+type decoder struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (d *decoder) read(b []byte) {
+	if d.err == nil {
+		_, d.err = io.ReadFull(d.r, b)
+	}
+}
+
+func (d *decoder) byte() byte {
+	var b [1]byte
+	d.read(b[:])
+	return b[0]
+}
+
+func (d *decoder) uint32() uint32 {
+	var b [4]byte
+	d.read(b[:])
+	return binary.BigEndian.Uint32(b[:])
+}
+
+// point reads a ring point written by appendPoint.
+func (d *decoder) point(wide bool) uint64 {
+	if !wide {
+		return uint64(d.uint32())
+	}
+	var b [8]byte
+	d.read(b[:])
+	return binary.BigEndian.Uint64(b[:])
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	var v uint64
+	v, d.err = binary.ReadUvarint(d.r)
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	var v int64
+	v, d.err = binary.ReadVarint(d.r)
+	return v
+}
+
+func (d *decoder) string() string {
+	n := d.uvarint()
+	if d.err == nil && n > uint64(d.r.Len()) {
+		d.err = io.ErrUnexpectedEOF
+	}
+	if d.err != nil {
+		return ""
+	}
+	b := make([]byte, n)
+	d.read(b)
+	return string(b)
+}
+
+// corrupt wraps the decoder's error for a truncated or malformed encoding.
+func (d *decoder) corrupt() error {
+	err := d.err
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("hashring: corrupt encoding: %w", err)
+}
@@ -0,0 +1,122 @@
+package hashring
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// A restored ring routes exactly like the original
+func TestMarshalBinaryRoundTrip(t *testing.T) {
+	src := New()
+	src.AddNodeWeighted("n3", 2)
+	src.AddNodeWithMeta("n1", 1, "zone-a")
+	src.AddNodeCapacity("big", 4<<30)
+	src.AddNode("n2")
+
+	data, err := src.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	var changes []Change
+	dst := New()
+	dst.AddNode("stale")
+	dst.OnChange(func(c Change) { changes = append(changes, c) })
+	if err := dst.UnmarshalBinary(data); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	if !bytes.Equal(src.CanonicalBytes(), dst.CanonicalBytes()) {
+		t.Fatalf("restored placement differs")
+	}
+	for i := 0; i < 1_000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if a, b := src.GetNodes(key, 3), dst.GetNodes(key, 3); fmt.Sprint(a) != fmt.Sprint(b) {
+			t.Fatalf("%s: %v vs %v", key, a, b)
+		}
+	}
+	if dst.Zone("n1") != "zone-a" || dst.Capacity("big") != 4<<30 || dst.nodes["n3"] != 2 {
+		t.Fatalf("metadata not restored")
+	}
+	if len(changes) != 5 || changes[0].Node != "stale" || changes[0].Added {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+
+	// Growing a restored node continues from its recorded vnode indices
+	src.AddNodeWeighted("n2", 2)
+	dst.AddNodeWeighted("n2", 2)
+	if !bytes.Equal(src.CanonicalBytes(), dst.CanonicalBytes()) {
+		t.Fatalf("restored ring diverged after a weight change")
+	}
+}
+
+// An empty ring round-trips cleanly
+func TestMarshalBinaryEmpty(t *testing.T) {
+	data, err := New().MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	r := New()
+	r.AddNode("n1")
+	if err := r.UnmarshalBinary(data); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if r.GetNode("k") != "" || len(r.nodes) != 0 {
+		t.Fatalf("ring not emptied")
+	}
+}
+
+// 64-bit points survive a round trip and require a 64-bit receiver
+func TestMarshalBinaryHasher64(t *testing.T) {
+	src := New(WithHasher64(shaHasher{}))
+	src.AddNode("n1")
+	src.AddNodeWeighted("n2", 2)
+
+	data, err := src.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	dst := New(WithHasher64(shaHasher{}))
+	if err := dst.UnmarshalBinary(data); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if !bytes.Equal(src.CanonicalBytes(), dst.CanonicalBytes()) {
+		t.Fatalf("restored placement differs")
+	}
+	if err := New(WithHasher(shaHasher{})).UnmarshalBinary(data); !errors.Is(err, ErrConfigMismatch) {
+		t.Fatalf("32-bit receiver: got %v, want ErrConfigMismatch", err)
+	}
+}
+
+// Mismatched configuration and corrupt input are rejected without changes
+func TestUnmarshalBinaryErrors(t *testing.T) {
+	src := New()
+	src.AddNode("n1")
+	src.AddNode("n2")
+	data, _ := src.MarshalBinary()
+
+	for name, r := range map[string]*HashRing{
+		"virtual nodes": New(WithVirtualNodes(50)),
+		"hasher":        New(WithHasher(fnvHasher{})),
+		"cluster":       New(WithCluster("other")),
+	} {
+		if err := r.UnmarshalBinary(data); !errors.Is(err, ErrConfigMismatch) {
+			t.Fatalf("%s: err = %v, want ErrConfigMismatch", name, err)
+		}
+	}
+
+	r := New()
+	r.AddNode("keep")
+	before := r.CanonicalBytes()
+	for _, bad := range [][]byte{nil, []byte("nope"), data[:len(data)-2], append(append([]byte(nil), data...), 0)} {
+		if err := r.UnmarshalBinary(bad); err == nil {
+			t.Fatalf("corrupt input %x accepted", bad)
+		}
+	}
+	if !bytes.Equal(before, r.CanonicalBytes()) {
+		t.Fatalf("failed unmarshal modified the ring")
+	}
+}
@@ -0,0 +1,106 @@
+package hashring
+
+import "sort"
+
+// FrozenRing is an immutable, lock-free view of a HashRing taken by Freeze.
+//
+// Owners are stored in a slice parallel to the sorted points, so a lookup
+// is a binary search and an index, with no lock and no map access. A
+// FrozenRing is safe for concurrent use and never observes later changes to
+// the ring it was taken from.
+type FrozenRing struct {
+	hasher   Hasher
+	hasher64 Hasher64
+	seed     uint32
+	nodes    int
+
+	// ring holds sorted hash points and owners[i] owns ring[i]
+	ring   []uint64
+	owners []Node
+}
+
+// Freeze returns an immutable copy of the ring's current placement for
+// fast lookups while the topology is known not to change, such as for the
+// duration of a request batch.
+func (h *HashRing) Freeze() *FrozenRing {
+	if snap := h.loadSnap(); snap != nil {
+		return snap.freeze()
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.freeze()
+}
+
+// freeze implements Freeze. Callers must hold the read lock.
+func (h *HashRing) freeze() *FrozenRing {
+	f := &FrozenRing{
+		hasher:   h.hasher,
+		hasher64: h.hasher64,
+		seed:     h.seed,
+		nodes:    len(h.nodes),
+		ring:     append([]uint64(nil), h.ring...),
+		owners:   make([]Node, len(h.ring)),
+	}
+	for i, p := range h.ring {
+		f.owners[i] = h.nodeMap[p]
+	}
+	return f
+}
+
+// hash computes the seeded hash of key exactly as the source ring does.
+func (f *FrozenRing) hash(key string) uint64 {
+	return hashKey(f.hasher, f.hasher64, f.seed, key)
+}
+
+// index returns the position of the first point clockwise from point.
+func (f *FrozenRing) index(point uint64) int {
+	i := sort.Search(len(f.ring), func(i int) bool {
+		return f.ring[i] >= point
+	})
+	if i == len(f.ring) {
+		i = 0
+	}
+	return i
+}
+
+// GetNode returns the primary node for key, as HashRing.GetNode did at
+// freeze time.
+func (f *FrozenRing) GetNode(key string) Node {
+	if len(f.ring) == 0 {
+		return ""
+	}
+	return f.owners[f.index(f.hash(key))]
+}
+
+// GetNodes returns up to replicas distinct nodes for key, as
+// HashRing.GetNodes did at freeze time.
+func (f *FrozenRing) GetNodes(key string, replicas int) []Node {
+	if len(f.ring) == 0 || replicas <= 0 {
+		return nil
+	}
+
+	max := min(replicas, f.nodes)
+	nodes := make([]Node, 0, max)
+
+	i := f.index(f.hash(key))
+	for step := 0; step < len(f.ring) && len(nodes) < max; step++ {
+		if n := f.owners[i]; !containsNode(nodes, n) {
+			nodes = append(nodes, n)
+		}
+		i = (i + 1) % len(f.ring)
+	}
+	return nodes
+}
+
+// containsNode reports whether n is in nodes. Replica sets are small, so a
+// linear scan beats a map.
+func containsNode(nodes []Node, n Node) bool {
+	for _, m := range nodes {
+		if m == n {
+			return true
+		}
+	}
+	return false
+}
@@ -1,10 +1,18 @@
 package hashring
 
 import (
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"hash/crc32"
+	"log"
+	"math"
+	"math/rand"
+	"slices"
 	"sort"
 	"strconv"
 	"sync"
+	"sync/atomic"
 )
 
 const (
@@ -17,8 +25,26 @@ const (
 	//
 	// Typical values: 50–200
 	DefaultVirtualNodes = 100
+
+	// DefaultReplicationFactor is the number of replicas returned by Route
+	// when no replication factor is configured.
+	DefaultReplicationFactor = 1
+
+	// DefaultMaxVirtualNodesPerNode caps the vnodes a single node may
+	// receive, so a typo in a weight cannot allocate tens of millions of
+	// points. With DefaultVirtualNodes it allows weights up to ~10000.
+	DefaultMaxVirtualNodesPerNode = 1 << 20
 )
 
+// ErrTooManyVirtualNodes is returned by AddNodeWeightedErr and UpdateWeight
+// when a weight would place more vnodes on one node than the configured
+// maximum.
+var ErrTooManyVirtualNodes = errors.New("hashring: too many virtual nodes")
+
+// ErrUnknownNode is returned by operations on a node that is not on the
+// ring.
+var ErrUnknownNode = errors.New("hashring: unknown node")
+
 // Node represents a physical node in the cluster.
 // Common examples:
 //   - "10.0.0.1:8080"
@@ -34,6 +60,16 @@ type Hasher interface {
 	Sum32(data []byte) uint32
 }
 
+// Hasher64 is a 64-bit hash function for the ring.
+//
+// A 32-bit point space makes vnode collisions routine once a cluster holds
+// hundreds of thousands of points; colliding vnodes are skipped, so the
+// affected nodes end up slightly underweight. Hashing into 64 bits makes
+// such collisions negligible at any practical ring size.
+type Hasher64 interface {
+	Sum64(data []byte) uint64
+}
+
 // crc32Hasher is the default hash implementation.
 //
 // CRC32 is:
@@ -44,6 +80,8 @@ type Hasher interface {
 // It is suitable for learning and moderate-scale systems.
 type crc32Hasher struct{}
 
+func (c crc32Hasher) Name() string { return "crc32" }
+
 func (c crc32Hasher) Sum32(b []byte) uint32 {
 	return crc32.ChecksumIEEE(b)
 }
@@ -58,22 +96,109 @@ func (c crc32Hasher) Sum32(b []byte) uint32 {
 // Internally, the ring is represented as a sorted slice of hash points
 // mapping to owning nodes.
 type HashRing struct {
-	mu sync.RWMutex
+	mu locker
 
 	// hasher produces 32-bit hash values for keys and virtual nodes
 	hasher Hasher
 
+	// hasher64, when set, replaces hasher and spreads points over the full
+	// 64-bit space; 32-bit hashers occupy only its low half
+	hasher64 Hasher64
+
 	// virts is the number of virtual nodes per unit weight
 	virts int
 
+	// maxVirts caps the vnodes placed for any single node
+	maxVirts int
+
+	// replicas is the replication factor used by Route
+	replicas int
+
 	// nodes tracks physical nodes and their weights
 	nodes map[Node]int
 
+	// zones records the failure domain of nodes added with metadata
+	zones map[Node]string
+
+	// capacities records nodes placed by capacity rather than weight
+	capacities map[Node]int64
+
+	// points records each node's placed vnodes in ascending index order
+	points map[Node][]vnode
+
 	// ring holds sorted hash points (virtual nodes)
-	ring []uint32
+	ring []uint64
 
 	// nodeMap maps each hash point to its owning physical node
-	nodeMap map[uint32]Node
+	nodeMap map[uint64]Node
+
+	// gen increases on every membership or pin change
+	gen uint64
+
+	// listeners are notified of membership changes registered with OnChange
+	listeners []func(Change)
+
+	// tracked maps the keys watched by OnKeyMigration listeners to their
+	// last reported owner
+	tracked            map[string]Node
+	migrationListeners []func(key string, from, to Node)
+
+	// strategy selects how mutations are published to readers
+	strategy RebuildStrategy
+
+	// snap holds the immutable copy served to lock-free readers under
+	// CopyOnWrite; it is nil under InPlace
+	snap atomic.Pointer[HashRing]
+
+	// snapShards holds the per-shard copies served under
+	// ShardedCopyOnWrite, and snapShardCount their configured number
+	snapShards     []snapShard
+	snapShardCount int
+
+	// delta accumulates point ownership changes during a mutation;
+	// lastDelta holds those of the most recently completed mutation
+	delta     []PointChange
+	lastDelta []PointChange
+
+	// pending collects changes made under the write lock until they are
+	// delivered by unlockAndNotify
+	pending []Change
+
+	// mixVnodes places virtual nodes by mixing a single node hash with the
+	// vnode index instead of hashing "<node>-<index>" strings
+	mixVnodes bool
+
+	// vnodeFormat, when set, builds virtual node identities in place of
+	// "<node>-<index>"
+	vnodeFormat func(node Node, index int) string
+
+	// seed perturbs every hash so rings with different seeds place keys
+	// independently; zero leaves hashes untouched
+	seed uint32
+
+	// scratch is reused to build virtual node identities without allocating
+	scratch []byte
+
+	// placed is reused to collect the points added by one resize
+	placed map[uint64]struct{}
+
+	// shards is the number of logical partitions configured with ShardMap;
+	// zero when the ring routes keys directly
+	shards int
+
+	// pins maps keys to preferred nodes consulted before the ring, and down
+	// holds nodes whose pins are suspended; see Pin and MarkDown
+	pins map[string]Node
+	down map[Node]struct{}
+
+	// loadFactor enables bounded-load routing when non-zero; load tracks the
+	// assignments it bounds
+	loadFactor float64
+	load       *loadTracker
+
+	// totalWeight is the sum of nodes' weights, refreshed by
+	// unlockAndNotify after every mutation for the load bound
+	totalWeight int
 }
 
 // New creates a new HashRing with optional configuration.
@@ -83,14 +208,23 @@ type HashRing struct {
 //   - DefaultVirtualNodes virtual nodes per weight unit
 func New(opts ...Option) *HashRing {
 	h := &HashRing{
-		hasher:  crc32Hasher{},
-		virts:   DefaultVirtualNodes,
-		nodes:   make(map[Node]int),
-		nodeMap: make(map[uint32]Node),
+		mu:         &sync.RWMutex{},
+		hasher:     crc32Hasher{},
+		virts:      DefaultVirtualNodes,
+		maxVirts:   DefaultMaxVirtualNodesPerNode,
+		replicas:   DefaultReplicationFactor,
+		nodes:      make(map[Node]int),
+		zones:      make(map[Node]string),
+		capacities: make(map[Node]int64),
+		points:     make(map[Node][]vnode),
+		nodeMap:    make(map[uint64]Node),
+		load:       &loadTracker{},
 	}
 	for _, opt := range opts {
 		opt(h)
 	}
+	h.initSnapshots()
+	h.publish()
 	return h
 }
 
@@ -109,6 +243,81 @@ func WithHasher(h Hasher) Option {
 	}
 }
 
+// WithHasher64 hashes keys and virtual nodes with a 64-bit hash function,
+// taking precedence over WithHasher. Lookups are unchanged for callers.
+// Points then span the full 64-bit space, so the point-level APIs have
+// 64-bit variants (OwnedRanges64, OwnerFootprint64, AddNodeWithPoints64,
+// PointChange.Point64); the 32-bit ones report nothing on such a ring.
+func WithHasher64(h Hasher64) Option {
+	return func(r *HashRing) {
+		r.hasher64 = h
+	}
+}
+
+// HasherName returns the name of the ring's hash function: the result of
+// its Name method if it has one ("crc32" for the default), otherwise its Go
+// type.
+func (h *HashRing) HasherName() string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.hasherName()
+}
+
+// SetHasher switches a live ring to a new hash function and rebuilds every
+// vnode position with it under the write lock.
+//
+// This remaps essentially all keys and is meant for planned hash function
+// migrations, not routine operation. Membership, weights, zones and
+// capacities are kept; nodes are re-placed in sorted order, so the result
+// matches a fresh ring built with the new hasher. Nodes added with
+// AddNodeWithPoints are re-placed by hashing like any other, and a hasher
+// set with WithHasher64 is dropped in favour of the 32-bit one. Every node is
+// reported to OnChange listeners as re-added, and LastDelta lists every
+// boundary whose owner changed.
+func (h *HashRing) SetHasher(hasher Hasher) {
+	h.mu.Lock()
+	old := &HashRing{ring: h.ring, nodeMap: h.nodeMap}
+
+	h.hasher = hasher
+	h.hasher64 = nil
+	h.ring = nil
+	h.nodeMap = make(map[uint64]Node, len(old.nodeMap))
+	h.points = make(map[Node][]vnode, len(h.points))
+	h.gen++
+
+	nodes := make([]Node, 0, len(h.nodes))
+	for n := range h.nodes {
+		nodes = append(nodes, n)
+	}
+	sortNodes(nodes)
+	for _, n := range nodes {
+		h.record(Change{Node: n, Added: true, Generation: h.gen})
+		if _, ok := h.capacities[n]; !ok {
+			count, _ := h.vnodesFor(h.nodes[n])
+			h.resize(n, count)
+		}
+	}
+	h.placeCapacities()
+	h.recordRebuild(old)
+	h.unlockAndNotify()
+}
+
+// recordRebuild replaces the pending delta after the ring was rebuilt from
+// scratch, where incremental deltas are meaningless: ownership is reported
+// at every boundary of the old and the new ring. old needs only ring and
+// nodeMap. Callers must hold the write lock.
+func (h *HashRing) recordRebuild(old *HashRing) {
+	h.delta = h.delta[:0]
+	for _, points := range [][]uint64{old.ring, h.ring} {
+		for _, p := range points {
+			if prev, next := old.ownerAt(p), h.ownerAt(p); prev != next {
+				h.delta = append(h.delta, h.pointChange(p, prev, next))
+			}
+		}
+	}
+}
+
 // WithVirtualNodes sets the number of virtual nodes per unit weight.
 func WithVirtualNodes(n int) Option {
 	return func(r *HashRing) {
@@ -116,9 +325,217 @@ func WithVirtualNodes(n int) Option {
 	}
 }
 
-// hash computes the hash value for a given key.
-func (h *HashRing) hash(key string) uint32 {
-	return h.hasher.Sum32([]byte(key))
+// WithMaxVirtualNodesPerNode sets the most vnodes any single node may
+// receive. AddNodeWeightedErr rejects weights above the cap; every other way
+// of adding a node clamps to it and logs a warning. Values below 1 keep
+// DefaultMaxVirtualNodesPerNode.
+func WithMaxVirtualNodesPerNode(n int) Option {
+	return func(h *HashRing) {
+		if n > 0 {
+			h.maxVirts = n
+		}
+	}
+}
+
+// WithReplicationFactor sets the number of distinct nodes returned by Route.
+func WithReplicationFactor(rf int) Option {
+	return func(r *HashRing) {
+		r.replicas = rf
+	}
+}
+
+// NewReplicated builds a ring for the common case: the given nodes at
+// weight 1, virts virtual nodes each and a default replication factor, ready
+// for Route.
+//
+// A non-positive virts falls back to DefaultVirtualNodes.
+func NewReplicated(nodes []Node, replicationFactor, virts int) *HashRing {
+	if virts <= 0 {
+		virts = DefaultVirtualNodes
+	}
+	h := New(WithVirtualNodes(virts), WithReplicationFactor(replicationFactor))
+	for _, n := range nodes {
+		h.AddNode(n)
+	}
+	return h
+}
+
+// WithCluster isolates the ring's placement under a cluster name.
+//
+// The name is hashed into a seed that perturbs every key and vnode hash, so
+// two rings with identical nodes but different cluster names place keys
+// independently (a hot key in one tenant says nothing about another), while
+// rings sharing a name agree exactly. An empty name keeps the default,
+// unseeded placement.
+func WithCluster(name string) Option {
+	return func(r *HashRing) {
+		if name == "" {
+			r.seed = 0
+			return
+		}
+		// Reserve zero for "unseeded"
+		r.seed = crc32.ChecksumIEEE([]byte(name)) | 1
+	}
+}
+
+// ShardMap configures the ring to own a fixed number of logical shards,
+// numbered 0 to shards-1, which ShardOwner and Shards map to physical nodes.
+//
+// Each shard is placed on the ring like a key named "shard-<index>", so a
+// membership change reassigns only the shards whose position falls in the
+// arcs that changed owner: adding one node to an n-node cluster moves
+// about shards/(n+1) shards, all of them to the new node.
+func ShardMap(shards int) Option {
+	return func(h *HashRing) {
+		h.shards = max(shards, 0)
+	}
+}
+
+// RebuildStrategy controls how ring mutations are made visible to lookups.
+type RebuildStrategy int
+
+const (
+	// InPlace mutates the ring under the write lock. It uses the least
+	// memory, but lookups take the read lock and block while a mutation
+	// is in progress. Best for write-heavy or memory-constrained rings.
+	InPlace RebuildStrategy = iota
+
+	// CopyOnWrite publishes an immutable copy of the ring after every
+	// mutation. GetNode, GetNodes and Route read that copy without taking
+	// any lock, at the cost of a full ring copy per mutation. Best for
+	// read-heavy rings with infrequent membership changes. Other
+	// introspection methods still use the read lock.
+	CopyOnWrite
+
+	// ShardedCopyOnWrite is CopyOnWrite with one copy per snapshot shard
+	// (GOMAXPROCS by default, see WithSnapshotShards), each on its own
+	// cache line. Readers pick a shard at random, so even the atomic
+	// snapshot pointer and the copy's memory are spread across cores
+	// instead of shared by all of them. Mutations pay one full copy per
+	// shard, and shards are refreshed one after another, so readers may
+	// briefly disagree while a mutation is being published. Meant for
+	// routers with very high read concurrency.
+	ShardedCopyOnWrite
+)
+
+// WithRebuildStrategy selects how mutations are published. The default is
+// InPlace.
+func WithRebuildStrategy(s RebuildStrategy) Option {
+	return func(r *HashRing) {
+		r.strategy = s
+	}
+}
+
+// WithPreallocatedCapacity reserves room for points ring points up front,
+// so rings in high-churn deployments do not regrow the point slice and
+// point map as nodes come and go. Size it for the largest expected ring
+// (virtual nodes times the sum of weights). Scratch buffers used while
+// placing vnodes are reused across mutations regardless of this option.
+func WithPreallocatedCapacity(points int) Option {
+	return func(h *HashRing) {
+		if points > 0 {
+			h.ring = make([]uint64, 0, points)
+			h.nodeMap = make(map[uint64]Node, points)
+		}
+	}
+}
+
+// WithMixedVirtualNodes enables the integer fast path for virtual node
+// placement.
+//
+// Instead of hashing "<node>-<index>" for every vnode, the node name is
+// hashed once and combined with each index through a cheap integer mix.
+// Distribution quality is equivalent, but placements differ from the
+// default string identity, so every ring in a cluster must agree on this
+// setting.
+func WithMixedVirtualNodes() Option {
+	return func(r *HashRing) {
+		r.mixVnodes = true
+	}
+}
+
+// WithVirtualNodeFormat replaces the "<node>-<index>" virtual node identity
+// with fn(node, index), so a ring can reproduce the placement of another
+// implementation, for example "node:index" hashed with SHA-1 through a
+// custom Hasher. Every ring in a cluster must use the same format. It is
+// ignored when WithMixedVirtualNodes is set, and it is not recorded by
+// MarshalBinary, so a restoring ring must be configured with it too.
+func WithVirtualNodeFormat(fn func(node Node, index int) string) Option {
+	return func(r *HashRing) {
+		r.vnodeFormat = fn
+	}
+}
+
+// WithoutLocking disables internal synchronization.
+//
+// Every lookup normally pays for an RWMutex acquire/release. In a strictly
+// single-goroutine data plane that cost buys nothing, so this option swaps
+// the mutex for no-ops.
+//
+// UNSAFE for concurrent use: a ring built with this option must never be
+// accessed from more than one goroutine at a time.
+func WithoutLocking() Option {
+	return func(r *HashRing) {
+		r.mu = noLock{}
+	}
+}
+
+// locker is the subset of sync.RWMutex used by HashRing.
+type locker interface {
+	Lock()
+	Unlock()
+	RLock()
+	RUnlock()
+}
+
+// noLock is a locker that does nothing, used by WithoutLocking.
+type noLock struct{}
+
+func (noLock) Lock()    {}
+func (noLock) Unlock()  {}
+func (noLock) RLock()   {}
+func (noLock) RUnlock() {}
+
+// hash computes the ring point for a given key.
+func (h *HashRing) hash(key string) uint64 {
+	return hashKey(h.hasher, h.hasher64, h.seed, key)
+}
+
+// hashKey hashes key with hasher64 when set, otherwise with hasher, and
+// applies seed as HashRing.seeded does.
+func hashKey(hasher Hasher, hasher64 Hasher64, seed uint32, key string) uint64 {
+	if hasher64 != nil {
+		return seeded64(hasher64.Sum64([]byte(key)), seed)
+	}
+	x := hasher.Sum32([]byte(key))
+	if seed == 0 {
+		return uint64(x)
+	}
+	return uint64(mix32(x, seed))
+}
+
+// seeded applies the cluster seed to a raw hash value.
+func (h *HashRing) seeded(x uint32) uint32 {
+	if h.seed == 0 {
+		return x
+	}
+	return mix32(x, h.seed)
+}
+
+// seeded64 applies seed to a raw 64-bit hash value.
+func seeded64(x uint64, seed uint32) uint64 {
+	if seed == 0 {
+		return x
+	}
+	return mix64(x, uint64(seed))
+}
+
+// sum hashes b into a ring point with the configured hasher and seed.
+func (h *HashRing) sum(b []byte) uint64 {
+	if h.hasher64 != nil {
+		return seeded64(h.hasher64.Sum64(b), h.seed)
+	}
+	return uint64(h.seeded(h.hasher.Sum32(b)))
 }
 
 // AddNode adds a node with default weight = 1.
@@ -130,128 +547,1835 @@ func (h *HashRing) AddNode(n Node) {
 //
 // Weight determines how many virtual nodes are placed on the ring.
 // A node with weight 2 receives approximately twice the key space
-// of a node with weight 1.
+// of a node with weight 1. Adding a node that is already present
+// changes its weight in place.
 func (h *HashRing) AddNodeWeighted(n Node, weight int) {
 	h.mu.Lock()
-	defer h.mu.Unlock()
+	h.addNode(n, weight)
+	h.unlockAndNotify()
+}
+
+// addNode places n with the given weight. If n is already on the ring its
+// vnode count is adjusted in place. Callers must hold the write lock.
+func (h *HashRing) addNode(n Node, weight int) {
+	count, ok := h.vnodesFor(weight)
+	if !ok {
+		log.Printf("hashring: weight %d for node %q exceeds %d vnodes, clamping", weight, n, h.maxVirts)
+	}
 
+	h.gen++
+	h.record(Change{Node: n, Added: true, Generation: h.gen})
 	h.nodes[n] = weight
-	total := h.virts * weight
+	h.resize(n, count)
+}
 
-	// Place virtual nodes on the ring
-	for i := 0; i < total; i++ {
-		for {
-			// Virtual node identity: <node>-<index>
-			point := h.hash(string(n) + "-" + strconv.Itoa(i))
+// AddNodeWeightedErr is like AddNodeWeighted but returns an error wrapping
+// ErrTooManyVirtualNodes, leaving the ring unchanged, when weight would
+// exceed the per-node vnode cap.
+func (h *HashRing) AddNodeWeightedErr(n Node, weight int) error {
+	h.mu.Lock()
+	if _, ok := h.vnodesFor(weight); !ok {
+		h.mu.Unlock()
+		return fmt.Errorf("%w: weight %d for node %q needs more than %d", ErrTooManyVirtualNodes, weight, n, h.maxVirts)
+	}
+	h.addNode(n, weight)
+	h.unlockAndNotify()
+	return nil
+}
 
-			// Avoid hash collisions (rare, but possible)
-			if _, exists := h.nodeMap[point]; !exists {
-				h.ring = append(h.ring, point)
-				h.nodeMap[point] = n
-				break
+// UpdateWeight changes the weight of a node already on the ring by adding
+// or removing only the difference in virtual nodes: a higher weight appends
+// vnodes with the next indices, a lower one removes the highest-indexed
+// ones. The node stays routable throughout, and only keys on the added or
+// removed points move, unlike RemoveNode followed by AddNodeWeighted.
+//
+// It returns an error wrapping ErrUnknownNode if n is not on the ring, or
+// ErrTooManyVirtualNodes if weight exceeds the per-node cap, and rejects
+// non-positive weights and nodes placed by capacity; the ring is unchanged
+// on error. Listeners see the update as a re-addition of n.
+func (h *HashRing) UpdateWeight(n Node, weight int) error {
+	h.mu.Lock()
+	if err := h.checkWeightUpdate(n, weight); err != nil {
+		h.mu.Unlock()
+		return err
+	}
+	h.addNode(n, weight)
+	h.unlockAndNotify()
+	return nil
+}
+
+// Rebalance tunes each node's vnode count to flatten the key distribution,
+// for small rings where a modest virtual node count leaves nodes visibly
+// uneven.
+//
+// Ownership is measured over sampleKeys generated keys and every node's
+// vnode count is scaled toward its weight-proportional share, within half
+// to double of its current count and the per-node cap. A fixed number of
+// rounds is run and the most even placement measured is kept.
+//
+// Weights are unchanged, but the tuned counts only last until a node's
+// weight is next set: AddNodeWeighted, UpdateWeight and SetNodes re-place it
+// at weight times the virtual node count. Rebalancing remaps the keys on
+// every added or removed vnode; each resized node is reported to listeners
+// as re-added, and LastDelta lists the net change between the placement
+// before and after, not the intermediate rounds.
+func (h *HashRing) Rebalance(sampleKeys int) {
+	h.mu.Lock()
+	h.rebalance(sampleKeys)
+	h.unlockAndNotify()
+}
+
+// rebalancePasses bounds how many measure-and-resize rounds Rebalance runs.
+const rebalancePasses = 20
+
+// rebalance implements Rebalance. Callers must hold the write lock.
+func (h *HashRing) rebalance(sampleKeys int) {
+	if sampleKeys <= 0 || len(h.nodes) < 2 {
+		return
+	}
+	points := make([]uint64, sampleKeys)
+	for i := range points {
+		points[i] = h.hash("rebalance-" + strconv.Itoa(i))
+	}
+	nodes := make([]Node, 0, len(h.nodes))
+	totalWeight := 0
+	for n, w := range h.nodes {
+		nodes = append(nodes, n)
+		totalWeight += w
+	}
+	sortNodes(nodes)
+
+	initial := make(map[Node]int, len(nodes))
+	for _, n := range nodes {
+		initial[n] = len(h.points[n])
+	}
+	old := &HashRing{ring: append([]uint64(nil), h.ring...), nodeMap: make(map[uint64]Node, len(h.nodeMap))}
+	for p, n := range h.nodeMap {
+		old.nodeMap[p] = n
+	}
+
+	// Resizing can overshoot, so every pass is measured and the placement
+	// with the smallest squared deviation from the targets is kept
+	best, bestSpread := initial, math.Inf(1)
+	for pass := 0; ; pass++ {
+		owned := make(map[Node]int, len(nodes))
+		for _, p := range points {
+			owned[h.ownerAt(p)]++
+		}
+		spread := 0.0
+		counts := make(map[Node]int, len(nodes))
+		for _, n := range nodes {
+			target := float64(sampleKeys) * float64(h.nodes[n]) / float64(totalWeight)
+			spread += math.Pow(float64(owned[n])/target-1, 2)
+			counts[n] = len(h.points[n])
+		}
+		if spread < bestSpread {
+			best, bestSpread = counts, spread
+		}
+		if pass == rebalancePasses {
+			break
+		}
+
+		for _, n := range nodes {
+			cur := len(h.points[n])
+			if cur == 0 {
+				continue
 			}
+			target := float64(sampleKeys) * float64(h.nodes[n]) / float64(totalWeight)
+			actual := max(float64(owned[n]), 0.5)
+			next := int(math.Round(float64(cur) * target / actual))
+			h.resize(n, min(max(next, cur/2, 1), 2*cur, h.maxVirts))
+		}
+	}
 
-			// Rehash on collision
-			i++
+	var resized []Node
+	for _, n := range nodes {
+		if len(h.points[n]) != best[n] {
+			h.resize(n, best[n])
+		}
+		if best[n] != initial[n] {
+			resized = append(resized, n)
 		}
 	}
+	h.recordRebuild(old)
+	if len(resized) == 0 {
+		return
+	}
+	h.gen++
+	for _, n := range resized {
+		h.record(Change{Node: n, Added: true, Generation: h.gen})
+	}
+}
 
-	// Keep ring sorted for binary search
-	sort.Slice(h.ring, func(i, j int) bool {
-		return h.ring[i] < h.ring[j]
-	})
+// checkWeightUpdate validates an UpdateWeight call. Callers must hold the
+// write lock.
+func (h *HashRing) checkWeightUpdate(n Node, weight int) error {
+	if _, ok := h.nodes[n]; !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownNode, n)
+	}
+	if _, ok := h.capacities[n]; ok {
+		return fmt.Errorf("hashring: node %q is placed by capacity", n)
+	}
+	if weight < 1 {
+		return fmt.Errorf("hashring: weight %d for node %q must be positive", weight, n)
+	}
+	if _, ok := h.vnodesFor(weight); !ok {
+		return fmt.Errorf("%w: weight %d for node %q needs more than %d", ErrTooManyVirtualNodes, weight, n, h.maxVirts)
+	}
+	return nil
 }
 
-// RemoveNode removes a node and all its virtual points from the ring.
+// vnodesFor returns the vnode count for weight, clamped to the per-node cap.
+// ok is false when clamping was needed. The bound is checked by division so
+// huge weights cannot overflow the product.
+func (h *HashRing) vnodesFor(weight int) (count int, ok bool) {
+	if weight > 0 && h.virts > 0 && weight > h.maxVirts/h.virts {
+		return h.maxVirts, false
+	}
+	return h.virts * weight, true
+}
+
+// resize grows or shrinks n's virtual nodes to exactly count points.
 //
-// Only keys owned by this node are remapped, preserving
-// the core consistent hashing guarantee.
-func (h *HashRing) RemoveNode(n Node) {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+// Growing appends vnodes with the next identity indices; shrinking removes
+// the highest-indexed ones. Either way only the delta of points changes
+// owner, so resizing moves the minimum number of keys. An index whose point
+// collides with an existing point is skipped. Callers must hold the write
+// lock.
+func (h *HashRing) resize(n Node, count int) {
+	cur := h.points[n]
 
-	delete(h.nodes, n)
+	switch {
+	case count > len(cur):
+		base := h.hash(string(n))
+		next := 0
+		if len(cur) > 0 {
+			next = cur[len(cur)-1].index + 1
+		}
+
+		if cur == nil {
+			cur = make([]vnode, 0, count)
+		}
+		if h.placed == nil {
+			h.placed = make(map[uint64]struct{}, count)
+		}
+		added := h.placed
+		clear(added)
 
-	newRing := make([]uint32, 0, len(h.ring))
-	newMap := make(map[uint32]Node)
+		// Place virtual nodes on the ring
+		for ; len(cur) < count; next++ {
+			point := h.vnodeHash(n, base, next)
 
-	for _, p := range h.ring {
-		if h.nodeMap[p] != n {
-			newRing = append(newRing, p)
-			newMap[p] = h.nodeMap[p]
+			// Avoid hash collisions (rare, but possible)
+			if _, exists := h.nodeMap[point]; exists {
+				continue
+			}
+			h.ring = append(h.ring, point)
+			h.nodeMap[point] = n
+			cur = append(cur, vnode{index: next, point: point})
+			added[point] = struct{}{}
+		}
+
+		// Keep ring sorted for binary search
+		slices.Sort(h.ring)
+		h.recordCaptured(n, added)
+
+	case count < len(cur):
+		removed := cur[count:]
+		for _, v := range removed {
+			delete(h.nodeMap, v.point)
+		}
+		cur = cur[:count]
+
+		// Filtering keeps the ring sorted
+		kept := h.ring[:0]
+		for _, p := range h.ring {
+			if _, ok := h.nodeMap[p]; ok {
+				kept = append(kept, p)
+			}
+		}
+		h.ring = kept
+
+		// Released points fall to their new clockwise successor
+		for _, v := range removed {
+			h.delta = append(h.delta, h.pointChange(v.point, n, h.ownerAt(v.point)))
 		}
 	}
 
-	h.ring = newRing
-	h.nodeMap = newMap
+	if len(cur) == 0 {
+		delete(h.points, n)
+		return
+	}
+	h.points[n] = cur
 }
 
-// GetNode returns the primary node responsible for the given key.
-//
-// Lookup is performed by hashing the key and selecting the
-// first node clockwise on the ring.
-func (h *HashRing) GetNode(key string) Node {
+// AddNodeWithMeta adds a node with a weight and a zone (rack, availability
+// zone or any other failure domain). Zones are used by zone-aware replica
+// selection; plain AddNode leaves a node's zone empty.
+func (h *HashRing) AddNodeWithMeta(n Node, weight int, zone string) {
+	h.mu.Lock()
+	h.addNode(n, weight)
+	h.zones[n] = zone
+	h.unlockAndNotify()
+}
+
+// Zone returns the zone n was added with. Nodes added without metadata
+// report the empty zone.
+func (h *HashRing) Zone(n Node) string {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
+	return h.zones[n]
+}
 
-	if len(h.ring) == 0 {
-		return ""
-	}
-
-	point := h.hash(key)
-	i := sort.Search(len(h.ring), func(i int) bool {
-		return h.ring[i] >= point
-	})
+// PointChange records a ring point whose owner changed during a mutation.
+// Old is empty for points captured on a previously empty ring; New is empty
+// for points released when the ring becomes empty.
+//
+// Point64 holds the point on every ring. Point holds it too on default
+// 32-bit rings, and is zero on rings configured with WithHasher64.
+type PointChange struct {
+	Point   uint32
+	Old     Node
+	New     Node
+	Point64 uint64
+}
 
-	// Wrap around if hash is beyond last point
-	if i == len(h.ring) {
-		i = 0
+// pointChange builds the PointChange for point p.
+func (h *HashRing) pointChange(p uint64, old, new Node) PointChange {
+	c := PointChange{Old: old, New: new, Point64: p}
+	if h.hasher64 == nil {
+		c.Point = uint32(p)
 	}
-
-	return h.nodeMap[h.ring[i]]
+	return c
 }
 
-// GetNodes returns up to `replicas` distinct nodes for the given key.
-//
-// Nodes are selected clockwise on the ring, skipping duplicates
-// caused by virtual nodes.
+// LastDelta returns the ring points whose ownership changed during the most
+// recent mutation, in the order the changes were applied.
 //
-// This is commonly used for:
-//   - replication
-//   - quorum systems
-//   - multi-node reads/writes
-func (h *HashRing) GetNodes(key string, replicas int) []Node {
+// Adding a node reports each of its new points together with the node that
+// previously owned that position (the clockwise successor); removing a node
+// reports each released point with the successor that inherits it. Because
+// keys map to the first point clockwise, a key changes owner exactly when
+// its position falls in an arc ending at one of these points, which lets
+// external indexes update incrementally instead of re-scanning.
+func (h *HashRing) LastDelta() []PointChange {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
+	return append([]PointChange(nil), h.lastDelta...)
+}
 
-	if len(h.ring) == 0 || replicas <= 0 {
-		return nil
+// recordCaptured appends a PointChange for each point newly placed for n,
+// taking the previous owner from the first clockwise point that existed
+// before. Callers must hold the write lock with the ring already sorted.
+func (h *HashRing) recordCaptured(n Node, added map[uint64]struct{}) {
+	for i, p := range h.ring {
+		if _, ok := added[p]; !ok {
+			continue
+		}
+		var old Node
+		// On an otherwise empty ring there is no previous owner to find
+		for step := 1; step < len(h.ring) && len(added) < len(h.ring); step++ {
+			q := h.ring[(i+step)%len(h.ring)]
+			if _, ok := added[q]; !ok {
+				old = h.nodeMap[q]
+				break
+			}
+		}
+		h.delta = append(h.delta, h.pointChange(p, old, n))
 	}
+}
 
-	// Cannot return more replicas than physical nodes
-	max := min(replicas, len(h.nodes))
-	nodes := make([]Node, 0, max)
-
-	point := h.hash(key)
-	i := sort.Search(len(h.ring), func(j int) bool {
-		return h.ring[j] >= point
-	})
+// vnode is a placed virtual node: its identity index and ring point.
+type vnode struct {
+	index int
+	point uint64
+}
 
-	// Handle wrap-around
-	if i == len(h.ring) {
-		i = 0
+// AddNodeCapacity adds or resizes a node whose share of the ring follows its
+// capacity in bytes rather than an integer weight.
+//
+// Capacity nodes share a vnode budget of virts per capacity node, split in
+// proportion to each node's fraction of the total capacity, so a node with
+// 4x the capacity of another owns ~4x the keyspace without rounding the
+// ratio to integer weights. When any capacity changes, every capacity
+// node's vnode count is recomputed and only the delta of points is added or
+// removed. Every node whose vnode count changes is reported to OnChange
+// listeners as re-added, and the generation advances. A non-positive
+// capacity removes the node.
+func (h *HashRing) AddNodeCapacity(n Node, bytes int64) {
+	h.mu.Lock()
+	if bytes <= 0 {
+		if _, ok := h.nodes[n]; ok {
+			h.removeNode(n)
+		}
+		h.unlockAndNotify()
+		return
 	}
 
-	seen := make(map[Node]struct{})
-
-	for len(nodes) < max {
-		n := h.nodeMap[h.ring[i]]
-		if _, ok := seen[n]; !ok {
-			seen[n] = struct{}{}
-			nodes = append(nodes, n)
+	_, existed := h.nodes[n]
+	h.capacities[n] = bytes
+	resized := h.placeCapacities()
+	if !existed || len(resized) > 0 {
+		h.gen++
+		if !existed {
+			h.record(Change{Node: n, Added: true, Generation: h.gen})
 		}
-		i = (i + 1) % len(h.ring)
+		for _, m := range resized {
+			if m != n || existed {
+				h.record(Change{Node: m, Added: true, Generation: h.gen})
+			}
+		}
+	}
+	h.unlockAndNotify()
+}
+
+// Capacity returns the capacity n was added with, or 0 for nodes placed by
+// weight.
+func (h *HashRing) Capacity(n Node) int64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.capacities[n]
+}
+
+// placeCapacities recomputes the vnode counts of all capacity nodes and
+// returns, in sorted order, those whose count changed. Nodes are processed
+// in sorted order so collision handling is deterministic. Callers must hold
+// the write lock.
+func (h *HashRing) placeCapacities() []Node {
+	var total int64
+	nodes := make([]Node, 0, len(h.capacities))
+	for n, b := range h.capacities {
+		total += b
+		nodes = append(nodes, n)
+	}
+	sortNodes(nodes)
+
+	budget := float64(h.virts * len(nodes))
+	var resized []Node
+	for _, n := range nodes {
+		count := max(1, int(math.Round(budget*float64(h.capacities[n])/float64(total))))
+
+		// Report the nearest integer weight for introspection
+		h.nodes[n] = max(1, int(math.Round(float64(count)/float64(h.virts))))
+		if len(h.points[n]) != count {
+			h.resize(n, count)
+			resized = append(resized, n)
+		}
+	}
+	return resized
+}
+
+// AddNodeWithPoints places n at exactly the given ring points instead of
+// hashing its virtual node identities.
+//
+// This is an advanced API for tests and research setups that need
+// placements reproducible across runs, machines and hasher versions. Points
+// already owned by another node are skipped, and calling it for a node that
+// is already present replaces all of its points. The node's reported weight
+// is its point count divided by the virtual nodes per weight unit, rounded
+// and at least 1; later weight-based resizes of n add hashed vnodes on top
+// of the explicit ones.
+func (h *HashRing) AddNodeWithPoints(n Node, points []uint32) {
+	wide := make([]uint64, len(points))
+	for i, p := range points {
+		wide[i] = uint64(p)
+	}
+	h.AddNodeWithPoints64(n, wide)
+}
+
+// AddNodeWithPoints64 is AddNodeWithPoints for rings configured with
+// WithHasher64, whose points span the full 64-bit space. On a default ring
+// points above math.MaxUint32 are skipped, since no key can hash there.
+func (h *HashRing) AddNodeWithPoints64(n Node, points []uint64) {
+	h.mu.Lock()
+	h.gen++
+	h.record(Change{Node: n, Added: true, Generation: h.gen})
+	h.resize(n, 0)
+
+	cur := make([]vnode, 0, len(points))
+	added := make(map[uint64]struct{}, len(points))
+	for i, p := range points {
+		if _, exists := h.nodeMap[p]; exists || p > h.maxPoint() {
+			continue
+		}
+		h.ring = append(h.ring, p)
+		h.nodeMap[p] = n
+		cur = append(cur, vnode{index: i, point: p})
+		added[p] = struct{}{}
+	}
+	slices.Sort(h.ring)
+	h.recordCaptured(n, added)
+
+	if len(cur) > 0 {
+		h.points[n] = cur
+	}
+	h.nodes[n] = max(1, int(math.Round(float64(len(cur))/float64(max(h.virts, 1)))))
+	h.unlockAndNotify()
+}
+
+// vnodeHash returns the ring point of the i-th virtual node of n.
+//
+// By default the virtual node identity is "<node>-<index>", assembled in a
+// reusable scratch buffer so placement does not allocate per vnode. With
+// WithMixedVirtualNodes the precomputed node hash (base) is mixed with the
+// index directly, and WithVirtualNodeFormat supplies the identity string.
+// Callers must hold the write lock.
+func (h *HashRing) vnodeHash(n Node, base uint64, i int) uint64 {
+	if h.mixVnodes {
+		if h.hasher64 != nil {
+			return mix64(base, uint64(i))
+		}
+		return uint64(mix32(uint32(base), uint32(i)))
+	}
+	if h.vnodeFormat != nil {
+		return h.sum([]byte(h.vnodeFormat(n, i)))
+	}
+	h.scratch = append(h.scratch[:0], n...)
+	h.scratch = append(h.scratch, '-')
+	h.scratch = strconv.AppendInt(h.scratch, int64(i), 10)
+	return h.sum(h.scratch)
+}
+
+// mix32 combines a node hash with a vnode index.
+//
+// The index is spread with the golden-ratio constant and the result is run
+// through the murmur3 finalizer so consecutive indices land far apart.
+func mix32(base, i uint32) uint32 {
+	x := base ^ (i * 0x9e3779b9)
+	x ^= x >> 16
+	x *= 0x85ebca6b
+	x ^= x >> 13
+	x *= 0xc2b2ae35
+	x ^= x >> 16
+	return x
+}
+
+// mix64 is the 64-bit counterpart of mix32, using the murmur3 fmix64
+// finalizer.
+func mix64(base, i uint64) uint64 {
+	x := base ^ (i * 0x9e3779b97f4a7c15)
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// RemoveNode removes a node and all its virtual points from the ring.
+//
+// Only keys owned by this node are remapped, preserving
+// the core consistent hashing guarantee. Removing a node that is not on the
+// ring is a no-op: the generation is unchanged and listeners are not called.
+func (h *HashRing) RemoveNode(n Node) {
+	h.mu.Lock()
+	if _, ok := h.nodes[n]; !ok {
+		h.mu.Unlock()
+		return
+	}
+	h.removeNode(n)
+	h.unlockAndNotify()
+}
+
+// removeNode drops n and its points. Callers must hold the write lock.
+func (h *HashRing) removeNode(n Node) {
+	h.gen++
+	h.record(Change{Node: n, Added: false, Generation: h.gen})
+	delete(h.nodes, n)
+	delete(h.zones, n)
+	h.resize(n, 0)
+
+	if _, ok := h.capacities[n]; ok {
+		delete(h.capacities, n)
+		for _, m := range h.placeCapacities() {
+			h.record(Change{Node: m, Added: true, Generation: h.gen})
+		}
+	}
+}
+
+// Drainer removes a node gradually. Each Step releases an equal share of
+// the node's vnodes, so its keys migrate in waves instead of all at once;
+// the last step removes the node. Create one with Drain.
+type Drainer struct {
+	ring   *HashRing
+	node   Node
+	start  int
+	weight int
+	steps  int
+	done   int
+}
+
+// Drain prepares to remove n over steps steps (at least 1). Nothing changes
+// until the first call to Step.
+//
+// Steps shrink the node the way UpdateWeight does, removing its
+// highest-indexed vnodes, but by vnode count rather than whole weight
+// units, so a weight-1 node drains as gradually as a heavy one. Weight
+// reports the remaining share rounded to the nearest integer, and at least
+// 1 while the node is on the ring. Every step but the last is reported to
+// OnChange listeners as an addition, like any other weight change, so
+// observers re-resolve the keys that moved.
+func (h *HashRing) Drain(n Node, steps int) *Drainer {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return &Drainer{ring: h, node: n, start: len(h.points[n]), weight: h.nodes[n], steps: max(steps, 1)}
+}
+
+// Step performs the next drain step and reports whether more remain. It is
+// a no-op returning false once the drain has finished or the node has left
+// the ring by other means.
+func (d *Drainer) Step() bool {
+	h := d.ring
+	h.mu.Lock()
+	defer h.unlockAndNotify()
+
+	if d.done >= d.steps {
+		return false
+	}
+	if _, ok := h.nodes[d.node]; !ok {
+		d.done = d.steps
+		return false
+	}
+
+	d.done++
+	if d.done == d.steps {
+		h.removeNode(d.node)
+		return false
+	}
+
+	left := float64(d.steps-d.done) / float64(d.steps)
+	h.gen++
+	h.record(Change{Node: d.node, Added: true, Generation: h.gen})
+	h.nodes[d.node] = max(1, int(math.Round(float64(d.weight)*left)))
+	h.resize(d.node, d.start*(d.steps-d.done)/d.steps)
+	return true
+}
+
+// Remaining returns how many steps are left.
+func (d *Drainer) Remaining() int {
+	d.ring.mu.RLock()
+	defer d.ring.mu.RUnlock()
+	return d.steps - d.done
+}
+
+// GetNode returns the primary node responsible for the given key.
+//
+// Lookup is performed by hashing the key and selecting the
+// first node clockwise on the ring. A key pinned with Pin returns its
+// preferred node instead while that node is up, and WithBoundedLoad skips
+// nodes that are over their load bound. Only GetNode applies the load
+// bound; see Pin for the other methods that honour pins.
+func (h *HashRing) GetNode(key string) Node {
+	if snap := h.loadSnap(); snap != nil {
+		return snap.route(key)
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.route(key)
+}
+
+// GroupByNode resolves every key's primary owner under a single read lock
+// and buckets the keys by owner, preserving their input order within each
+// bucket. It is the natural input to a scatter/gather fan-out with one
+// request per node. An empty ring returns an empty map.
+func (h *HashRing) GroupByNode(keys []string) map[Node][]string {
+	if snap := h.loadSnap(); snap != nil {
+		return snap.groupByNode(keys)
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.groupByNode(keys)
+}
+
+// groupByNode implements GroupByNode. Callers must hold the read lock.
+func (h *HashRing) groupByNode(keys []string) map[Node][]string {
+	groups := make(map[Node][]string, len(h.nodes))
+	if len(h.ring) == 0 {
+		return groups
+	}
+	for _, k := range keys {
+		n := h.pinnedOwner(k)
+		groups[n] = append(groups[n], k)
+	}
+	return groups
+}
+
+// route implements GetNode: pins first, then the load bound, then the
+// ring owner. Callers must hold the read lock.
+func (h *HashRing) route(key string) Node {
+	if n, ok := h.pinned(key); ok {
+		return n
+	}
+	if h.loadFactor > 0 {
+		return h.boundedOwner(key)
+	}
+	return h.getNode(key)
+}
+
+// pinnedOwner returns key's pinned node, or its ring owner when it has no
+// usable pin. Unlike route it ignores load, so the result changes only
+// with the generation. Callers must hold the read lock.
+func (h *HashRing) pinnedOwner(key string) Node {
+	if n, ok := h.pinned(key); ok {
+		return n
+	}
+	return h.getNode(key)
+}
+
+// getNode returns the ring owner of key, ignoring pins and load, so it only
+// changes with membership. Callers must hold the read lock.
+func (h *HashRing) getNode(key string) Node {
+	return h.ownerAt(h.hash(key))
+}
+
+// ownerAt returns the first node clockwise from point. Callers must hold the
+// read lock.
+func (h *HashRing) ownerAt(point uint64) Node {
+	if len(h.ring) == 0 {
+		return ""
+	}
+
+	i := sort.Search(len(h.ring), func(i int) bool {
+		return h.ring[i] >= point
+	})
+
+	// Wrap around if hash is beyond last point
+	if i == len(h.ring) {
+		i = 0
+	}
+
+	return h.nodeMap[h.ring[i]]
+}
+
+// GetNodes returns up to `replicas` distinct nodes for the given key.
+//
+// Nodes are selected clockwise on the ring, skipping duplicates
+// caused by virtual nodes.
+//
+// This is commonly used for:
+//   - replication
+//   - quorum systems
+//   - multi-node reads/writes
+//
+// With unequal weights the clockwise walk behaves like weighted sampling
+// without replacement: the primary is chosen proportionally to weight, and
+// each subsequent replica is the next distinct owner, which is again chosen
+// proportionally among the remaining nodes because vnodes are spread
+// uniformly. Heavy nodes therefore do not crowd light nodes out of replica
+// sets beyond what their weight implies.
+func (h *HashRing) GetNodes(key string, replicas int) []Node {
+	if snap := h.loadSnap(); snap != nil {
+		return snap.getNodes(key, replicas)
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.getNodes(key, replicas)
+}
+
+// getNodes resolves up to replicas distinct owners of key. Callers must hold
+// the read lock.
+func (h *HashRing) getNodes(key string, replicas int) []Node {
+	return h.nodesAt(h.hash(key), replicas)
+}
+
+// GetNodesExcluding is like GetNodes but silently skips the nodes in
+// exclude, for routing around unhealthy nodes without mutating the ring.
+// Healthy replicas keep their usual positions, so only the slots of
+// excluded nodes are filled by the next distinct nodes clockwise. If too
+// few nodes remain, fewer than replicas are returned.
+func (h *HashRing) GetNodesExcluding(key string, replicas int, exclude map[Node]struct{}) []Node {
+	if snap := h.loadSnap(); snap != nil {
+		return snap.getNodesExcluding(key, replicas, exclude)
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.getNodesExcluding(key, replicas, exclude)
+}
+
+// getNodesExcluding implements GetNodesExcluding. Callers must hold the
+// read lock.
+func (h *HashRing) getNodesExcluding(key string, replicas int, exclude map[Node]struct{}) []Node {
+	if replicas <= 0 {
+		return nil
+	}
+	var nodes []Node
+	h.walk(key, func(n Node) bool {
+		if _, skip := exclude[n]; !skip {
+			nodes = append(nodes, n)
+		}
+		return len(nodes) < replicas
+	})
+	return nodes
+}
+
+// nodesAt returns up to replicas distinct nodes clockwise from point.
+// Callers must hold the read lock.
+func (h *HashRing) nodesAt(point uint64, replicas int) []Node {
+	if len(h.ring) == 0 || replicas <= 0 {
+		return nil
+	}
+
+	// Cannot return more replicas than physical nodes
+	max := min(replicas, len(h.nodes))
+	nodes := make([]Node, 0, max)
+
+	i := sort.Search(len(h.ring), func(j int) bool {
+		return h.ring[j] >= point
+	})
+
+	// Handle wrap-around
+	if i == len(h.ring) {
+		i = 0
+	}
+
+	seen := make(map[Node]struct{})
+
+	// Bound the walk to one lap: a node registered with weight 0 (or whose
+	// points were all lost to collisions) counts towards len(h.nodes) but
+	// can never be found, and must not make the walk spin forever.
+	for step := 0; step < len(h.ring) && len(nodes) < max; step++ {
+		n := h.nodeMap[h.ring[i]]
+		if _, ok := seen[n]; !ok {
+			seen[n] = struct{}{}
+			nodes = append(nodes, n)
+		}
+		i = (i + 1) % len(h.ring)
+	}
+
+	return nodes
+}
+
+// Route returns the replica set for key using the ring's configured
+// replication factor. It is shorthand for GetNodes(key, rf).
+func (h *HashRing) Route(key string) []Node {
+	if snap := h.loadSnap(); snap != nil {
+		return snap.getNodes(key, snap.replicas)
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.getNodes(key, h.replicas)
+}
+
+// PickReplica chooses one node from key's replica set at random, weighted
+// by each node's configured weight, for spreading reads across replicas in
+// proportion to their capacity. rng supplies the randomness so callers can
+// make selection deterministic.
+//
+// Nodes with weight 0 are never picked unless every replica has weight 0,
+// in which case the primary is returned. An empty ring returns "".
+func (h *HashRing) PickReplica(key string, replicas int, rng *rand.Rand) Node {
+	if snap := h.loadSnap(); snap != nil {
+		return snap.pickReplica(key, replicas, rng)
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.pickReplica(key, replicas, rng)
+}
+
+// pickReplica implements PickReplica. Callers must hold the read lock.
+func (h *HashRing) pickReplica(key string, replicas int, rng *rand.Rand) Node {
+	nodes := h.getNodes(key, replicas)
+	if len(nodes) == 0 {
+		return ""
+	}
+
+	total := 0
+	for _, n := range nodes {
+		total += h.nodes[n]
+	}
+	if total <= 0 {
+		return nodes[0]
+	}
+
+	x := rng.Intn(total)
+	for _, n := range nodes {
+		if x -= h.nodes[n]; x < 0 {
+			return n
+		}
+	}
+	return nodes[len(nodes)-1]
+}
+
+// GetNodesZoneHybrid returns localReplicas distinct nodes from the primary's
+// own zone, followed by remoteReplicas distinct nodes from other zones.
+//
+// The primary is always the first local replica. Both groups are filled by
+// walking the ring clockwise from the key, so the selection is as stable as
+// GetNodes under membership changes. If a group cannot be filled it is
+// returned short; nodes are never borrowed from the other group. Nodes
+// without a zone belong to the empty zone.
+func (h *HashRing) GetNodesZoneHybrid(key string, localReplicas, remoteReplicas int) []Node {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var local, remote []Node
+	zone := ""
+	first := true
+
+	h.walk(key, func(n Node) bool {
+		if first {
+			zone = h.zones[n]
+			first = false
+		}
+		if h.zones[n] == zone {
+			if len(local) < localReplicas {
+				local = append(local, n)
+			}
+		} else if len(remote) < remoteReplicas {
+			remote = append(remote, n)
+		}
+		return len(local) < localReplicas || len(remote) < remoteReplicas
+	})
+
+	return append(local, remote...)
+}
+
+// GetNodesSpread returns up to replicas distinct nodes for key, spread
+// across as many zones as possible.
+//
+// The ring is walked clockwise from the key and a node is taken only if its
+// zone is not yet represented, so the first replicas all sit in different
+// failure domains. When there are fewer zones than replicas, the remaining
+// slots are filled with the skipped nodes in the order the walk met them,
+// rather than returning fewer replicas; the result is only short when the
+// ring has fewer nodes than replicas. The primary is always first. Nodes
+// without a zone share the empty zone.
+func (h *HashRing) GetNodesSpread(key string, replicas int) []Node {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if replicas <= 0 {
+		return nil
+	}
+
+	var spread, skipped []Node
+	zones := make(map[string]struct{})
+	h.walk(key, func(n Node) bool {
+		if _, ok := zones[h.zones[n]]; ok {
+			skipped = append(skipped, n)
+			return true
+		}
+		zones[h.zones[n]] = struct{}{}
+		spread = append(spread, n)
+		return len(spread) < replicas
+	})
+
+	// Not enough zones: reuse same-zone nodes in ring order
+	for _, n := range skipped {
+		if len(spread) == replicas {
+			break
+		}
+		spread = append(spread, n)
+	}
+	return spread
+}
+
+// walk visits each distinct physical node once, in clockwise order starting
+// from key's position, until fn returns false or every node has been seen.
+// Callers must hold the read lock.
+func (h *HashRing) walk(key string, fn func(Node) bool) {
+	if len(h.ring) == 0 {
+		return
+	}
+
+	point := h.hash(key)
+	i := sort.Search(len(h.ring), func(j int) bool {
+		return h.ring[j] >= point
+	})
+
+	seen := make(map[Node]struct{})
+	for step := 0; step < len(h.ring); step++ {
+		n := h.nodeMap[h.ring[(i+step)%len(h.ring)]]
+		if _, ok := seen[n]; ok {
+			continue
+		}
+		seen[n] = struct{}{}
+		if !fn(n) {
+			return
+		}
+	}
+}
+
+// GetNodeComposite returns the primary node for a key made of several parts,
+// such as (tenant, key).
+//
+// Each part is length-prefixed before hashing, so compositions that would
+// be ambiguous under plain concatenation, like ("t1", "k") and ("t", "1k"),
+// hash independently.
+func (h *HashRing) GetNodeComposite(parts ...string) Node {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.ownerAt(h.hashParts(parts))
+}
+
+// GetNodesComposite is the replica-set counterpart of GetNodeComposite.
+func (h *HashRing) GetNodesComposite(replicas int, parts ...string) []Node {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.nodesAt(h.hashParts(parts), replicas)
+}
+
+// hashParts hashes the uvarint-length-prefixed encoding of parts.
+func (h *HashRing) hashParts(parts []string) uint64 {
+	var buf []byte
+	for _, p := range parts {
+		buf = binary.AppendUvarint(buf, uint64(len(p)))
+		buf = append(buf, p...)
+	}
+	return h.sum(buf)
+}
+
+// ShardOwner returns the node that owns the given logical shard. It returns
+// "" when the shard is outside the range configured with ShardMap or the
+// ring is empty.
+func (h *HashRing) ShardOwner(shard int) Node {
+	if snap := h.loadSnap(); snap != nil {
+		return snap.shardOwner(shard)
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.shardOwner(shard)
+}
+
+// Shards returns the logical shards owned by n in ascending order.
+func (h *HashRing) Shards(n Node) []int {
+	if snap := h.loadSnap(); snap != nil {
+		return snap.shardsOf(n)
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.shardsOf(n)
+}
+
+// shardOwner implements ShardOwner. Callers must hold the read lock.
+func (h *HashRing) shardOwner(shard int) Node {
+	if shard < 0 || shard >= h.shards {
+		return ""
+	}
+	return h.getNode("shard-" + strconv.Itoa(shard))
+}
+
+// shardsOf implements Shards. Callers must hold the read lock.
+func (h *HashRing) shardsOf(n Node) []int {
+	var out []int
+	for i := 0; i < h.shards; i++ {
+		if h.shardOwner(i) == n {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// Range is a closed interval [Start, End] of hash values.
+type Range struct {
+	Start, End uint32
+}
+
+// Range64 is a Range on a ring configured with WithHasher64.
+type Range64 struct {
+	Start, End uint64
+}
+
+// OwnedRanges returns the hash ranges for which n is the primary owner,
+// sorted by Start. A key is owned by n exactly when its hash falls in one of
+// them. Adjacent arcs are merged, and the arc that wraps past the top of the
+// hash space is split in two.
+//
+// Rings configured with WithHasher64 have no 32-bit ranges and return nil;
+// use OwnedRanges64 for them.
+func (h *HashRing) OwnedRanges(n Node) []Range {
+	if snap := h.loadSnap(); snap != nil {
+		return snap.ownedRanges32(n)
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.ownedRanges32(n)
+}
+
+// ownedRanges32 implements OwnedRanges. Callers must hold the read lock.
+func (h *HashRing) ownedRanges32(n Node) []Range {
+	if h.hasher64 != nil {
+		return nil
+	}
+	wide := h.ownedRanges(n)
+	out := make([]Range, len(wide))
+	for i, r := range wide {
+		out[i] = Range{Start: uint32(r.Start), End: uint32(r.End)}
+	}
+	return out
+}
+
+// OwnedRanges64 is OwnedRanges with 64-bit bounds, valid on every ring.
+func (h *HashRing) OwnedRanges64(n Node) []Range64 {
+	if snap := h.loadSnap(); snap != nil {
+		return snap.ownedRanges(n)
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.ownedRanges(n)
+}
+
+// maxPoint returns the top of the ring's hash space.
+func (h *HashRing) maxPoint() uint64 {
+	if h.hasher64 != nil {
+		return math.MaxUint64
 	}
+	return math.MaxUint32
+}
+
+// Distribution returns the fraction of the hash space each node owns as
+// primary, computed exactly from the arc lengths between consecutive ring
+// points. The fractions sum to 1; nodes without points report 0 and an
+// empty ring returns an empty map.
+func (h *HashRing) Distribution() map[Node]float64 {
+	if snap := h.loadSnap(); snap != nil {
+		return snap.distribution()
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.distribution()
+}
+
+// distribution implements Distribution. Callers must hold the read lock.
+func (h *HashRing) distribution() map[Node]float64 {
+	shares := make(map[Node]float64, len(h.nodes))
+	if len(h.ring) == 0 {
+		return shares
+	}
+	for n := range h.nodes {
+		shares[n] = 0
+	}
+	if len(h.ring) == 1 {
+		shares[h.nodeMap[h.ring[0]]] = 1
+		return shares
+	}
+
+	space := float64(h.maxPoint()) + 1
+	for i, p := range h.ring {
+		var arc uint64
+		if i > 0 {
+			arc = p - h.ring[i-1]
+		} else {
+			// The first point owns the wrap-around arc past the last point;
+			// for the 64-bit space maxPoint()+1 overflows to the right 0
+			arc = p - h.ring[len(h.ring)-1] + h.maxPoint() + 1
+		}
+		shares[h.nodeMap[p]] += float64(arc) / space
+	}
+	return shares
+}
+
+// Imbalance returns the ratio of the largest to the smallest node share
+// reported by Distribution: 1 for a perfectly even ring, +Inf if some node
+// owns nothing, and 0 for an empty ring. Shares are not weight-adjusted,
+// so weighted rings are expected to exceed 1.
+func (h *HashRing) Imbalance() float64 {
+	shares := h.Distribution()
+	if len(shares) == 0 {
+		return 0
+	}
+	lo, hi := math.Inf(1), 0.0
+	for _, s := range shares {
+		lo, hi = min(lo, s), max(hi, s)
+	}
+	if lo == 0 {
+		return math.Inf(1)
+	}
+	return hi / lo
+}
+
+// ownedRanges implements OwnedRanges64. Callers must hold the read lock.
+func (h *HashRing) ownedRanges(n Node) []Range64 {
+	var arcs []Range64
+	for i, p := range h.ring {
+		if h.nodeMap[p] != n {
+			continue
+		}
+		if i > 0 {
+			arcs = append(arcs, Range64{Start: h.ring[i-1] + 1, End: p})
+			continue
+		}
+		// The first point also owns everything past the last point
+		arcs = append(arcs, Range64{Start: 0, End: p})
+		if last, top := h.ring[len(h.ring)-1], h.maxPoint(); last != top {
+			arcs = append(arcs, Range64{Start: last + 1, End: top})
+		}
+	}
+	sort.Slice(arcs, func(i, j int) bool { return arcs[i].Start < arcs[j].Start })
+
+	var out []Range64
+	for _, a := range arcs {
+		if k := len(out) - 1; k >= 0 && out[k].End+1 == a.Start {
+			out[k].End = a.End
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// OwnershipFilter returns a predicate reporting whether n is the primary
+// owner of a key, for scanning large key lists without a full lookup per
+// key. The predicate binary-searches n's OwnedRanges taken when the filter
+// is built, so it does not see later membership changes and needs no lock.
+func (h *HashRing) OwnershipFilter(n Node) func(key string) bool {
+	if snap := h.loadSnap(); snap != nil {
+		return snap.ownershipFilter(n)
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.ownershipFilter(n)
+}
+
+// ownershipFilter implements OwnershipFilter. The hasher is captured with
+// the ranges so a later SetHasher cannot mix placements. Callers must hold
+// the read lock.
+func (h *HashRing) ownershipFilter(n Node) func(key string) bool {
+	ranges := h.ownedRanges(n)
+	hasher, hasher64, seed := h.hasher, h.hasher64, h.seed
+	return func(key string) bool {
+		x := hashKey(hasher, hasher64, seed, key)
+		i := sort.Search(len(ranges), func(i int) bool { return ranges[i].Start > x }) - 1
+		return i >= 0 && x <= ranges[i].End
+	}
+}
+
+// Neighbors returns, in sorted order, the distinct nodes that immediately
+// follow n's vnodes clockwise, skipping n's own points. These are exactly
+// the nodes that inherit n's keys if n is removed, so neighbors share n's
+// failure blast radius.
+func (h *HashRing) Neighbors(n Node) []Node {
+	if snap := h.loadSnap(); snap != nil {
+		return snap.neighbors(n)
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.neighbors(n)
+}
+
+// neighbors implements Neighbors. Callers must hold the read lock.
+func (h *HashRing) neighbors(n Node) []Node {
+	var out []Node
+	for _, v := range h.points[n] {
+		// The successor of a point we own is the first point clockwise
+		// that belongs to someone else.
+		i := sort.Search(len(h.ring), func(i int) bool { return h.ring[i] > v.point })
+		for step := 0; step < len(h.ring); step++ {
+			owner := h.nodeMap[h.ring[(i+step)%len(h.ring)]]
+			if owner != n {
+				if !containsNode(out, owner) {
+					out = append(out, owner)
+				}
+				break
+			}
+		}
+	}
+	sortNodes(out)
+	return out
+}
+
+// IsOwner reports whether n is among the first `replicas` nodes in the
+// preference list for key.
+//
+// With replicas = 1 this is equivalent to GetNode(key) == n. Larger values
+// answer "is this key one of my responsibilities?" in leaderless systems
+// where every replica accepts reads and writes.
+func (h *HashRing) IsOwner(key string, n Node, replicas int) bool {
+	for _, owner := range h.GetNodes(key, replicas) {
+		if owner == n {
+			return true
+		}
+	}
+	return false
+}
+
+// VirtualNodeCount returns how many ring points currently map to n.
+//
+// For a node added with weight w this is virts * w, capped at the
+// per-node maximum (see WithMaxVirtualNodesPerNode): an index whose point
+// collides with another vnode is skipped and the next index is used
+// instead. Unknown nodes report 0.
+func (h *HashRing) VirtualNodeCount(n Node) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 
+	return len(h.points[n])
+}
+
+// Nodes returns the physical nodes on the ring, sorted by name.
+func (h *HashRing) Nodes() []Node {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	nodes := make([]Node, 0, len(h.nodes))
+	for n := range h.nodes {
+		nodes = append(nodes, n)
+	}
+	sortNodes(nodes)
 	return nodes
 }
+
+// ContainsNode reports whether n is on the ring.
+func (h *HashRing) ContainsNode(n Node) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, ok := h.nodes[n]
+	return ok
+}
+
+// Weight returns n's weight and whether n is on the ring. Nodes added by
+// capacity report the nearest integer weight of their vnode share.
+func (h *HashRing) Weight(n Node) (int, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	w, ok := h.nodes[n]
+	return w, ok
+}
+
+// OwnerFootprint returns the primary owner of key together with every ring
+// point that owner occupies, in ascending order.
+//
+// It is meant for visualizations that highlight a node's share of the ring.
+// An empty ring returns ("", nil). Rings configured with WithHasher64
+// return nil points; use OwnerFootprint64 for them.
+func (h *HashRing) OwnerFootprint(key string) (Node, []uint32) {
+	if snap := h.loadSnap(); snap != nil {
+		return snap.ownerFootprint32(key)
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.ownerFootprint32(key)
+}
+
+// ownerFootprint32 implements OwnerFootprint. Callers must hold the read
+// lock.
+func (h *HashRing) ownerFootprint32(key string) (Node, []uint32) {
+	owner, wide := h.ownerFootprint(key)
+	if wide == nil || h.hasher64 != nil {
+		return owner, nil
+	}
+	points := make([]uint32, len(wide))
+	for i, p := range wide {
+		points[i] = uint32(p)
+	}
+	return owner, points
+}
+
+// OwnerFootprint64 is OwnerFootprint with 64-bit points, valid on every
+// ring.
+func (h *HashRing) OwnerFootprint64(key string) (Node, []uint64) {
+	if snap := h.loadSnap(); snap != nil {
+		return snap.ownerFootprint(key)
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.ownerFootprint(key)
+}
+
+// ownerFootprint implements OwnerFootprint64. Callers must hold the read lock.
+func (h *HashRing) ownerFootprint(key string) (Node, []uint64) {
+	owner := h.getNode(key)
+	if owner == "" {
+		return "", nil
+	}
+
+	vns := h.points[owner]
+	points := make([]uint64, len(vns))
+	for i, v := range vns {
+		points[i] = v.point
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i] < points[j] })
+	return owner, points
+}
+
+// SetNodes converges the ring to the desired membership.
+//
+// Nodes absent from desired are removed, new nodes are added, and nodes whose
+// weight changed are re-placed at the new weight. Nodes already present with
+// the same weight are untouched, so their keys do not move.
+func (h *HashRing) SetNodes(desired map[Node]int) {
+	h.mu.Lock()
+	h.setNodes(desired)
+	h.unlockAndNotify()
+}
+
+// setNodes implements SetNodes. Callers must hold the write lock.
+func (h *HashRing) setNodes(desired map[Node]int) {
+	// Nodes re-placed at a new weight keep their zone
+	keep := make(map[Node]string)
+	for n, w := range h.nodes {
+		if dw, ok := desired[n]; !ok || dw != w {
+			if z, ok := h.zones[n]; ok && desired[n] > 0 {
+				keep[n] = z
+			}
+			h.removeNode(n)
+		}
+	}
+	defer func() {
+		for n, z := range keep {
+			h.zones[n] = z
+		}
+	}()
+
+	// Add in sorted order so collision handling is deterministic
+	adds := make([]Node, 0, len(desired))
+	for n := range desired {
+		if _, ok := h.nodes[n]; !ok {
+			adds = append(adds, n)
+		}
+	}
+	sortNodes(adds)
+	for _, n := range adds {
+		h.addNode(n, desired[n])
+	}
+}
+
+// SetNodesPlan reports what SetNodes(desired) would do without mutating the
+// ring.
+//
+// adds and removes list the membership delta in sorted order (weight changes
+// appear in neither). moved is the fraction of sampleKeys whose primary owner
+// would change; it is computed on a private clone, so the estimate reflects
+// weight changes as well as membership changes.
+func (h *HashRing) SetNodesPlan(desired map[Node]int, sampleKeys []string) (adds, removes []Node, moved float64) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for n := range desired {
+		if _, ok := h.nodes[n]; !ok {
+			adds = append(adds, n)
+		}
+	}
+	for n := range h.nodes {
+		if _, ok := desired[n]; !ok {
+			removes = append(removes, n)
+		}
+	}
+	sortNodes(adds)
+	sortNodes(removes)
+
+	if len(sampleKeys) == 0 {
+		return adds, removes, 0
+	}
+
+	next := h.clone()
+	next.setNodes(desired)
+
+	changed := 0
+	for _, k := range sampleKeys {
+		if h.getNode(k) != next.getNode(k) {
+			changed++
+		}
+	}
+	return adds, removes, float64(changed) / float64(len(sampleKeys))
+}
+
+// Migration reports a key whose primary owner changed from From to To.
+type Migration struct {
+	Key      string
+	From, To Node
+}
+
+// AddNodeWithMigration adds n like AddNodeWeighted and reports which of
+// sampleKeys changed primary owner, in sampleKeys order, so the new owner
+// can be pre-warmed before traffic is cut over. Only the given keys are
+// inspected.
+func (h *HashRing) AddNodeWithMigration(n Node, weight int, sampleKeys []string) []Migration {
+	h.mu.Lock()
+	before := h.primaries(sampleKeys)
+	h.addNode(n, weight)
+	moved := h.migrations(sampleKeys, before)
+	h.unlockAndNotify()
+	return moved
+}
+
+// RemoveNodeWithMigration removes n like RemoveNode and reports which of
+// sampleKeys changed primary owner, in sampleKeys order. If the ring
+// becomes empty the reported To is empty. Like RemoveNode, it is a no-op
+// returning nil when n is not on the ring.
+func (h *HashRing) RemoveNodeWithMigration(n Node, sampleKeys []string) []Migration {
+	h.mu.Lock()
+	if _, ok := h.nodes[n]; !ok {
+		h.mu.Unlock()
+		return nil
+	}
+	before := h.primaries(sampleKeys)
+	h.removeNode(n)
+	moved := h.migrations(sampleKeys, before)
+	h.unlockAndNotify()
+	return moved
+}
+
+// primaries resolves the primary owner of each key. Callers must hold the
+// read lock.
+func (h *HashRing) primaries(keys []string) []Node {
+	owners := make([]Node, len(keys))
+	for i, k := range keys {
+		owners[i] = h.getNode(k)
+	}
+	return owners
+}
+
+// migrations lists the keys whose owner differs from before. Callers must
+// hold the read lock.
+func (h *HashRing) migrations(keys []string, before []Node) []Migration {
+	var moved []Migration
+	for i, k := range keys {
+		if to := h.getNode(k); to != before[i] {
+			moved = append(moved, Migration{Key: k, From: before[i], To: to})
+		}
+	}
+	return moved
+}
+
+// ClaimSources maps each of candidateKeys that added takes over as primary
+// to the node that owned it before, so a data mover knows where to pull
+// each key from.
+//
+// If added is already on the ring, the previous owners are computed on a
+// clone with added removed; otherwise the claim is previewed on a clone
+// with added placed at weight 1, and the current owners are the sources.
+// Keys added does not claim are omitted.
+func (h *HashRing) ClaimSources(added Node, candidateKeys []string) map[string]Node {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	before, after := h, h.clone()
+	if _, ok := h.nodes[added]; ok {
+		before, after = after, h
+		before.removeNode(added)
+	} else {
+		after.addNode(added, 1)
+	}
+
+	sources := make(map[string]Node)
+	for _, k := range candidateKeys {
+		if after.getNode(k) != added {
+			continue
+		}
+		if prev := before.getNode(k); prev != "" {
+			sources[k] = prev
+		}
+	}
+	return sources
+}
+
+// ReplicaOverlap measures how similar the replica sets of two rings are.
+//
+// For each sample key it takes the Jaccard similarity |A∩B| / |A∪B| of the
+// key's replicas on old and new, and returns the average: 1 means every
+// sampled replica set is unchanged, 0 means none share a node. Two empty
+// sets count as identical, as does an empty sample.
+func ReplicaOverlap(old, new *HashRing, sampleKeys []string, replicas int) float64 {
+	if len(sampleKeys) == 0 {
+		return 1
+	}
+
+	total := 0.0
+	for _, k := range sampleKeys {
+		a, b := old.GetNodes(k, replicas), new.GetNodes(k, replicas)
+
+		union := len(a)
+		shared := 0
+		for _, n := range b {
+			if containsNode(a, n) {
+				shared++
+			} else {
+				union++
+			}
+		}
+		if union == 0 {
+			total++
+			continue
+		}
+		total += float64(shared) / float64(union)
+	}
+	return total / float64(len(sampleKeys))
+}
+
+// clone returns a deep copy of the ring's configuration and placement. The
+// copy has its own lock and starts with no recorded load. Callers must hold
+// at least the read lock.
+func (h *HashRing) clone() *HashRing {
+	c := &HashRing{
+		mu:          &sync.RWMutex{},
+		hasher:      h.hasher,
+		hasher64:    h.hasher64,
+		virts:       h.virts,
+		maxVirts:    h.maxVirts,
+		replicas:    h.replicas,
+		mixVnodes:   h.mixVnodes,
+		vnodeFormat: h.vnodeFormat,
+		seed:        h.seed,
+		shards:      h.shards,
+		loadFactor:  h.loadFactor,
+		load:        &loadTracker{},
+		totalWeight: h.totalWeight,
+		gen:         h.gen,
+		nodes:       make(map[Node]int, len(h.nodes)),
+		zones:       make(map[Node]string, len(h.zones)),
+		capacities:  make(map[Node]int64, len(h.capacities)),
+		points:      make(map[Node][]vnode, len(h.points)),
+		ring:        append([]uint64(nil), h.ring...),
+		nodeMap:     make(map[uint64]Node, len(h.nodeMap)),
+	}
+	for n, w := range h.nodes {
+		c.nodes[n] = w
+	}
+	for n, z := range h.zones {
+		c.zones[n] = z
+	}
+	for n, b := range h.capacities {
+		c.capacities[n] = b
+	}
+	for n, v := range h.points {
+		c.points[n] = append([]vnode(nil), v...)
+	}
+	for p, n := range h.nodeMap {
+		c.nodeMap[p] = n
+	}
+	if len(h.pins) > 0 {
+		c.pins = make(map[string]Node, len(h.pins))
+		for k, n := range h.pins {
+			c.pins[k] = n
+		}
+	}
+	if len(h.down) > 0 {
+		c.down = make(map[Node]struct{}, len(h.down))
+		for n := range h.down {
+			c.down[n] = struct{}{}
+		}
+	}
+	return c
+}
+
+// sortNodes sorts nodes in place by name.
+func sortNodes(nodes []Node) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i] < nodes[j]
+	})
+}
+
+// Generation returns a counter that increases whenever ring membership or a
+// pin changes. Clients can cache lookups alongside it and revalidate cheaply.
+func (h *HashRing) Generation() uint64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.gen
+}
+
+// ResolveCached validates a cached (key -> node) lookup taken at generation
+// gen.
+//
+// If the ring has not changed since gen, cached is returned as-is without
+// hashing the key. Otherwise the key is re-resolved. The returned bool
+// reports whether cached is still the correct owner; the returned
+// generation should replace the caller's cached one.
+func (h *HashRing) ResolveCached(key string, cached Node, gen uint64) (Node, uint64, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if gen == h.gen {
+		return cached, h.gen, true
+	}
+	owner := h.pinnedOwner(key)
+	return owner, h.gen, owner == cached
+}
+
+// Change describes a single membership mutation delivered to OnChange
+// listeners. Generation is the ring generation right after the change.
+type Change struct {
+	Node       Node
+	Added      bool
+	Generation uint64
+}
+
+// OnChange registers fn to be called after every membership change.
+//
+// Listeners run synchronously on the mutating goroutine once the write lock
+// has been released, so they may freely query the ring. A weight change made
+// through SetNodes is reported as a removal followed by an addition.
+func (h *HashRing) OnChange(fn func(Change)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.listeners = append(h.listeners, fn)
+}
+
+// OnKeyMigration registers fn to be called for every tracked key (see
+// TrackKeys) whose ring owner changes during a mutation, so that, for
+// example, the old owner can evict its cached copy. Callbacks run like
+// OnChange listeners, after the write lock is released and after the
+// mutation's Change events, in key order. Pins and the load bound do not
+// count as ownership changes.
+func (h *HashRing) OnKeyMigration(fn func(key string, from, to Node)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.migrationListeners = append(h.migrationListeners, fn)
+}
+
+// TrackKeys adds keys to the set watched by OnKeyMigration, recording their
+// current owners.
+func (h *HashRing) TrackKeys(keys ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.tracked == nil {
+		h.tracked = make(map[string]Node, len(keys))
+	}
+	for _, k := range keys {
+		h.tracked[k] = h.getNode(k)
+	}
+}
+
+// UntrackKeys removes keys from the set watched by OnKeyMigration.
+func (h *HashRing) UntrackKeys(keys ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, k := range keys {
+		delete(h.tracked, k)
+	}
+}
+
+// trackedMigrations re-resolves every tracked key, updates the recorded
+// owners and returns the keys that moved, sorted by key. Callers must hold
+// the write lock.
+func (h *HashRing) trackedMigrations() []Migration {
+	if len(h.migrationListeners) == 0 || len(h.tracked) == 0 {
+		return nil
+	}
+	var moved []Migration
+	for k, from := range h.tracked {
+		if to := h.getNode(k); to != from {
+			moved = append(moved, Migration{Key: k, From: from, To: to})
+			h.tracked[k] = to
+		}
+	}
+	sort.Slice(moved, func(i, j int) bool { return moved[i].Key < moved[j].Key })
+	return moved
+}
+
+// record queues c for delivery. Callers must hold the write lock.
+func (h *HashRing) record(c Change) {
+	if len(h.listeners) > 0 {
+		h.pending = append(h.pending, c)
+	}
+}
+
+// unlockAndNotify publishes the new ring to lock-free readers, releases the
+// write lock and delivers queued changes and key migrations. Every public
+// mutator must finish through it.
+func (h *HashRing) unlockAndNotify() {
+	h.totalWeight = 0
+	for _, w := range h.nodes {
+		h.totalWeight += w
+	}
+	h.publish()
+	// LastDelta copies, so the previous delta's array can be reused
+	h.lastDelta, h.delta = h.delta, h.lastDelta[:0]
+	pending, listeners := h.pending, h.listeners
+	moved, migrationListeners := h.trackedMigrations(), h.migrationListeners
+	h.pending = nil
+	h.mu.Unlock()
+
+	for _, c := range pending {
+		for _, fn := range listeners {
+			fn(c)
+		}
+	}
+	for _, m := range moved {
+		for _, fn := range migrationListeners {
+			fn(m.Key, m.From, m.To)
+		}
+	}
+}
+
+// CanonicalBytes serializes the ring placement in a fixed order so rings
+// built independently can be compared byte for byte.
+//
+// The encoding is, for each ring point in ascending order, the point as a
+// 4-byte big-endian integer (8 bytes with WithHasher64) followed by the
+// owner's name as a uvarint-length-prefixed string. Two rings produce
+// identical bytes exactly when every point maps to the same owner,
+// regardless of the order in which nodes were added. (Placement only
+// depends on add order when two vnodes collide on the same point, which
+// forces one of them to skip an index.)
+func (h *HashRing) CanonicalBytes() []byte {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	buf := make([]byte, 0, len(h.ring)*12)
+	for _, p := range h.ring {
+		owner := h.nodeMap[p]
+		buf = h.appendPoint(buf, p)
+		buf = binary.AppendUvarint(buf, uint64(len(owner)))
+		buf = append(buf, owner...)
+	}
+	return buf
+}
+
+// Verify checks the ring's internal invariants: the points are strictly
+// ascending (so sorted and free of duplicates), every point has an owner
+// that is still a member, nodeMap holds no points missing from the ring, and
+// each node's recorded vnodes are owned by it. It returns a descriptive error
+// for the first violation found. Verify is a cheap guard for tests; a
+// correctly used ring always passes.
+func (h *HashRing) Verify() error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for i, p := range h.ring {
+		if i > 0 && h.ring[i-1] >= p {
+			return fmt.Errorf("hashring: ring not strictly ascending at %d: %d then %d", i, h.ring[i-1], p)
+		}
+		owner, ok := h.nodeMap[p]
+		if !ok {
+			return fmt.Errorf("hashring: point %d has no owner", p)
+		}
+		if _, member := h.nodes[owner]; !member {
+			return fmt.Errorf("hashring: point %d owned by non-member %q", p, owner)
+		}
+	}
+	if len(h.nodeMap) != len(h.ring) {
+		return fmt.Errorf("hashring: nodeMap has %d points, ring has %d", len(h.nodeMap), len(h.ring))
+	}
+	for n, vns := range h.points {
+		for _, v := range vns {
+			if owner := h.nodeMap[v.point]; owner != n {
+				return fmt.Errorf("hashring: vnode %d of %q at point %d is owned by %q", v.index, n, v.point, owner)
+			}
+		}
+	}
+	return nil
+}
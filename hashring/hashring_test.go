@@ -1,10 +1,19 @@
 package hashring
 
 import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"math"
+	"math/rand"
+	"sort"
 	"sync"
 	"testing"
+	"time"
 )
 
 // Balance with 2 equal nodes
@@ -31,46 +40,1645 @@ func TestBalance(t *testing.T) {
 	}
 }
 
+// Integer-mixed vnode placement is as uniform as string identities
+func TestMixedVirtualNodesBalance(t *testing.T) {
+	spread := func(opts ...Option) float64 {
+		r := New(opts...)
+		const nodes = 20
+		for i := 0; i < nodes; i++ {
+			r.AddNode(Node(fmt.Sprintf("n%d", i)))
+		}
+
+		count := make(map[Node]int)
+		const N = 500_000
+		for i := 0; i < N; i++ {
+			count[r.GetNode(fmt.Sprintf("key-%d", i))]++
+		}
+
+		// Standard deviation of per-node share, in percentage points
+		mean := 100.0 / nodes
+		var sum float64
+		for _, c := range count {
+			d := float64(c)/float64(N)*100 - mean
+			sum += d * d
+		}
+		return math.Sqrt(sum / nodes)
+	}
+
+	str := spread()
+	mixed := spread(WithMixedVirtualNodes())
+	t.Logf("Share stddev: string %.3f, mixed %.3f", str, mixed)
+
+	// Both are sampling noise around ~1/sqrt(virts); allow generous slack
+	if mixed > str*1.5 {
+		t.Fatalf("mixed placement less uniform: %.3f vs %.3f", mixed, str)
+	}
+}
+
 // Removing a node removes all its virtual points
 func TestRemoveBalance(t *testing.T) {
 	r := New()
 	r.AddNode("n1")
 	r.AddNode("n2")
 
-	r.RemoveNode("n1")
+	r.RemoveNode("n1")
+
+	if _, exists := r.nodes["n1"]; exists {
+		t.Fatalf("node n1 not removed from nodes map")
+	}
+
+	// Ensure no ring point maps to removed node
+	for _, p := range r.ring {
+		if r.nodeMap[p] == "n1" {
+			t.Fatalf("found virtual node of removed node n1")
+		}
+	}
+}
+
+// Weights approximate 1:2 ratio
+func TestWeighted(t *testing.T) {
+	r := New()
+	r.AddNodeWeighted("n1", 1)
+	r.AddNodeWeighted("n2", 2)
+
+	count := make(map[string]int)
+	const N = 1_000_000
+
+	for i := 0; i < N; i++ {
+		n := string(r.GetNode(fmt.Sprintf("key-%d", i)))
+		count[n]++
+	}
+
+	p1 := float64(count["n1"]) / float64(N) * 100
+	p2 := float64(count["n2"]) / float64(N) * 100
+	t.Logf("Weighted n1: %.2f%%, n2: %.2f%% (target ~33.3/66.7)", p1, p2)
+
+	if math.Abs(p1-33.3) > 5 || math.Abs(p2-66.7) > 5 {
+		t.Fatalf("bad weight distribution: %.2f/%.2f", p1, p2)
+	}
+}
+
+// Virtual node counts follow weight × virts
+func TestVirtualNodeCount(t *testing.T) {
+	r := New(WithVirtualNodes(100))
+	r.AddNodeWeighted("n1", 1)
+	r.AddNodeWeighted("n2", 2)
+
+	c1 := r.VirtualNodeCount("n1")
+	c2 := r.VirtualNodeCount("n2")
+	t.Logf("VirtualNodeCount n1:%d n2:%d", c1, c2)
+
+	// Collisions skip an index, so counts are exact
+	if c1 != 100 {
+		t.Fatalf("n1: expected 100 points, got %d", c1)
+	}
+	if c2 != 200 {
+		t.Fatalf("n2: expected 200 points, got %d", c2)
+	}
+	if c1+c2 != len(r.ring) {
+		t.Fatalf("counts %d+%d do not cover ring of %d", c1, c2, len(r.ring))
+	}
+	if got := r.VirtualNodeCount("missing"); got != 0 {
+		t.Fatalf("unknown node: expected 0, got %d", got)
+	}
+}
+
+// SetNodesPlan predicts the relocation SetNodes actually causes
+func TestSetNodesPlan(t *testing.T) {
+	r := New()
+	r.AddNode("n1")
+	r.AddNode("n2")
+	r.AddNode("n3")
+
+	desired := map[Node]int{"n1": 1, "n3": 2, "n4": 1}
+
+	keys := make([]string, 20_000)
+	before := make([]Node, len(keys))
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+		before[i] = r.GetNode(keys[i])
+	}
+
+	adds, removes, moved := r.SetNodesPlan(desired, keys)
+	if fmt.Sprint(adds) != "[n4]" || fmt.Sprint(removes) != "[n2]" {
+		t.Fatalf("unexpected delta: adds=%v removes=%v", adds, removes)
+	}
+
+	// Planning must not touch the live ring
+	for i, k := range keys {
+		if r.GetNode(k) != before[i] {
+			t.Fatalf("SetNodesPlan mutated the ring")
+		}
+	}
+
+	r.SetNodes(desired)
+
+	changed := 0
+	for i, k := range keys {
+		if r.GetNode(k) != before[i] {
+			changed++
+		}
+	}
+	actual := float64(changed) / float64(len(keys))
+	t.Logf("Planned moved=%.4f actual=%.4f", moved, actual)
+
+	if moved != actual {
+		t.Fatalf("plan %.4f does not match actual %.4f", moved, actual)
+	}
+	if len(r.nodes) != 3 || r.nodes["n3"] != 2 {
+		t.Fatalf("unexpected membership after SetNodes: %v", r.nodes)
+	}
+}
+
+// Cluster names isolate placement deterministically
+func TestWithCluster(t *testing.T) {
+	build := func(name string) *HashRing {
+		r := New(WithCluster(name))
+		for i := 0; i < 5; i++ {
+			r.AddNode(Node(fmt.Sprintf("n%d", i)))
+		}
+		return r
+	}
+
+	a1, a2, b := build("tenant-a"), build("tenant-a"), build("tenant-b")
+
+	const N = 10_000
+	same, diff := 0, 0
+	for i := 0; i < N; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if a1.GetNode(key) == a2.GetNode(key) {
+			same++
+		}
+		if a1.GetNode(key) != b.GetNode(key) {
+			diff++
+		}
+	}
+
+	if same != N {
+		t.Fatalf("same cluster name diverged on %d keys", N-same)
+	}
+
+	// Independent placement over 5 nodes differs on ~80% of keys
+	frac := float64(diff) / N
+	t.Logf("tenant-a vs tenant-b differ on %.2f%% of keys", frac*100)
+	if frac < 0.6 {
+		t.Fatalf("clusters too correlated: only %.2f%% differ", frac*100)
+	}
+}
+
+// Cached lookups short-circuit until the generation moves
+func TestResolveCached(t *testing.T) {
+	r := New()
+	r.AddNode("n1")
+	r.AddNode("n2")
+
+	gen := r.Generation()
+
+	// A matching generation returns the cached value without re-hashing,
+	// even if the caller cached something bogus
+	n, g, ok := r.ResolveCached("key", "bogus", gen)
+	if n != "bogus" || g != gen || !ok {
+		t.Fatalf("expected short-circuit, got %s %d %v", n, g, ok)
+	}
+
+	// Find a key that moves when n3 joins
+	var key string
+	var owner Node
+	for i := 0; ; i++ {
+		key = fmt.Sprintf("key-%d", i)
+		owner = r.GetNode(key)
+		c := r.clone()
+		c.addNode("n3", 1)
+		if c.getNode(key) != owner {
+			break
+		}
+	}
+
+	r.AddNode("n3")
+	n, g, ok = r.ResolveCached(key, owner, gen)
+	if g == gen {
+		t.Fatalf("generation did not advance")
+	}
+	if ok || n != "n3" {
+		t.Fatalf("expected re-resolve to n3, got %s valid=%v", n, ok)
+	}
+
+	// Revalidating with the fresh generation short-circuits again
+	if _, _, ok := r.ResolveCached(key, n, g); !ok {
+		t.Fatalf("fresh generation not accepted")
+	}
+}
+
+// Canonical encoding is independent of add order
+func TestCanonicalBytes(t *testing.T) {
+	orders := [][]Node{
+		{"n1", "n2", "n3", "n4"},
+		{"n4", "n3", "n2", "n1"},
+		{"n2", "n4", "n1", "n3"},
+	}
+
+	var want []byte
+	for i, order := range orders {
+		r := New()
+		for _, n := range order {
+			r.AddNodeWeighted(n, len(n))
+		}
+		got := r.CanonicalBytes()
+		if i == 0 {
+			want = got
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("order %v produced different CanonicalBytes", order)
+		}
+	}
+
+	r := New()
+	r.AddNode("n1")
+	if bytes.Equal(r.CanonicalBytes(), want) {
+		t.Fatalf("different membership produced identical bytes")
+	}
+}
+
+// Capacity-placed nodes own keyspace in proportion to their bytes
+func TestAddNodeCapacity(t *testing.T) {
+	const GB = int64(1) << 30
+
+	// Enough vnodes that the small node's share is not dominated by noise
+	r := New(WithVirtualNodes(400))
+	r.AddNodeCapacity("small", 1*GB)
+	r.AddNodeCapacity("big", 4*GB)
+
+	if got := r.Capacity("big"); got != 4*GB {
+		t.Fatalf("Capacity(big) = %d", got)
+	}
+
+	count := make(map[Node]int)
+	const N = 500_000
+	for i := 0; i < N; i++ {
+		count[r.GetNode(fmt.Sprintf("key-%d", i))]++
+	}
+	ratio := float64(count["big"]) / float64(count["small"])
+	t.Logf("Capacity big/small keyspace ratio: %.2f (target 4)", ratio)
+	if ratio < 3 || ratio > 5 {
+		t.Fatalf("4x capacity owns %.2fx keyspace", ratio)
+	}
+
+	// Growing small to 2GB only moves keys onto small
+	before := make(map[string]Node)
+	for i := 0; i < 20_000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		before[key] = r.GetNode(key)
+	}
+	r.AddNodeCapacity("small", 2*GB)
+	for key, was := range before {
+		if now := r.GetNode(key); now != was && now != "small" {
+			t.Fatalf("%s moved %s -> %s on capacity growth", key, was, now)
+		}
+	}
+	if r.VirtualNodeCount("small")+r.VirtualNodeCount("big") != len(r.ring) {
+		t.Fatalf("vnode counts do not cover the ring")
+	}
+}
+
+// Resizing an existing capacity node advances the generation and notifies
+func TestCapacityChangeNotifies(t *testing.T) {
+	r := New()
+	r.AddNodeCapacity("a", 100)
+	r.AddNodeCapacity("b", 100)
+
+	var changes []Change
+	r.OnChange(func(c Change) { changes = append(changes, c) })
+	gen := r.Generation()
+
+	const N = 10_000
+	before := make([]Node, N)
+	for i := range before {
+		before[i] = r.GetNode(fmt.Sprintf("key-%d", i))
+	}
+	r.AddNodeCapacity("a", 400)
+
+	if r.Generation() != gen+1 {
+		t.Fatalf("generation %d -> %d, want one step", gen, r.Generation())
+	}
+	got := make(map[Node]bool)
+	for _, c := range changes {
+		if !c.Added || c.Generation != gen+1 {
+			t.Fatalf("unexpected change %+v", c)
+		}
+		got[c.Node] = true
+	}
+	if !got["a"] || !got["b"] || len(changes) != 2 {
+		t.Fatalf("listeners saw %v, want both resized nodes", changes)
+	}
+
+	// a goes from half to four fifths of the keyspace, so ~30% of keys move
+	moved := 0
+	for i, was := range before {
+		now := r.GetNode(fmt.Sprintf("key-%d", i))
+		if now == was {
+			continue
+		}
+		if was != "b" || now != "a" {
+			t.Fatalf("key-%d moved %s -> %s", i, was, now)
+		}
+		moved++
+	}
+	if share := float64(moved) / N; share < 0.2 || share > 0.4 {
+		t.Fatalf("capacity change moved %.2f of keys, want ~0.3", share)
+	}
+
+	// An unchanged capacity is not a change
+	changes = nil
+	r.AddNodeCapacity("a", 400)
+	if r.Generation() != gen+1 || len(changes) != 0 {
+		t.Fatalf("re-adding the same capacity notified %v", changes)
+	}
+}
+
+// LastDelta reports exactly the points a new node captured
+func TestLastDelta(t *testing.T) {
+	r := New()
+	r.AddNode("n1")
+	r.AddNode("n2")
+	r.AddNode("n3")
+
+	before := r.clone()
+	r.AddNode("n4")
+	delta := r.LastDelta()
+
+	if len(delta) != r.VirtualNodeCount("n4") {
+		t.Fatalf("expected %d changes, got %d", r.VirtualNodeCount("n4"), len(delta))
+	}
+	for _, c := range delta {
+		if c.New != "n4" {
+			t.Fatalf("point %d: new owner %s, want n4", c.Point, c.New)
+		}
+		if r.nodeMap[c.Point64] != "n4" {
+			t.Fatalf("point %d not owned by n4", c.Point)
+		}
+		if want := before.ownerAt(c.Point64); c.Old != want {
+			t.Fatalf("point %d: old owner %s, want %s", c.Point, c.Old, want)
+		}
+	}
+
+	// Every key that moved lies in an arc ending at a reported point
+	captured := make(map[uint64]bool)
+	for _, c := range delta {
+		captured[c.Point64] = true
+	}
+	for i := 0; i < 20_000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		moved := before.getNode(key) != r.getNode(key)
+		i := sort.Search(len(r.ring), func(j int) bool { return r.ring[j] >= r.hash(key) }) % len(r.ring)
+		if moved != captured[r.ring[i]] {
+			t.Fatalf("%s: moved=%v but point %d captured=%v", key, moved, r.ring[i], captured[r.ring[i]])
+		}
+	}
+
+	// Removal hands the points to their successors
+	r.RemoveNode("n4")
+	delta = r.LastDelta()
+	if len(delta) != 100 {
+		t.Fatalf("expected 100 released points, got %d", len(delta))
+	}
+	for _, c := range delta {
+		if c.Old != "n4" || c.New != r.ownerAt(c.Point64) {
+			t.Fatalf("bad release %+v", c)
+		}
+	}
+}
+
+// OwnerFootprint lists exactly the owner's vnodes, sorted
+func TestOwnerFootprint(t *testing.T) {
+	r := New()
+	r.AddNode("n1")
+	r.AddNodeWeighted("n2", 2)
+	r.AddNode("n3")
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		owner, points := r.OwnerFootprint(key)
+
+		if owner != r.GetNode(key) {
+			t.Fatalf("%s: owner %s, GetNode %s", key, owner, r.GetNode(key))
+		}
+		if want := DefaultVirtualNodes * r.nodes[owner]; len(points) != want {
+			t.Fatalf("%s: footprint has %d points, want %d", key, len(points), want)
+		}
+		if !sort.SliceIsSorted(points, func(i, j int) bool { return points[i] < points[j] }) {
+			t.Fatalf("%s: footprint not sorted", key)
+		}
+		for _, p := range points {
+			if r.nodeMap[uint64(p)] != owner {
+				t.Fatalf("%s: point %d maps to %s, want %s", key, p, r.nodeMap[uint64(p)], owner)
+			}
+		}
+	}
+
+	if n, points := New().OwnerFootprint("k"); n != "" || points != nil {
+		t.Fatalf("empty ring footprint = %q, %v", n, points)
+	}
+}
+
+// Adding a node to a shard map moves only the shards it takes over
+func TestShardMapRebalance(t *testing.T) {
+	const shards = 256
+	r := New(ShardMap(shards))
+	for i := 1; i <= 4; i++ {
+		r.AddNode(Node(fmt.Sprintf("n%d", i)))
+	}
+
+	before := make([]Node, shards)
+	for i := range before {
+		before[i] = r.ShardOwner(i)
+	}
+
+	r.AddNode("n5")
+
+	moved := 0
+	for i := range before {
+		after := r.ShardOwner(i)
+		if after == before[i] {
+			continue
+		}
+		if after != "n5" {
+			t.Fatalf("shard %d moved %s -> %s, not to the new node", i, before[i], after)
+		}
+		moved++
+	}
+
+	if got := len(r.Shards("n5")); got != moved {
+		t.Fatalf("Shards(n5) = %d, moved = %d", got, moved)
+	}
+
+	ideal := shards / 5
+	t.Logf("moved %d shards (ideal %d)", moved, ideal)
+	if moved < ideal/2 || moved > ideal*3/2 {
+		t.Fatalf("moved %d shards, want roughly %d", moved, ideal)
+	}
+
+	total := 0
+	for _, n := range []Node{"n1", "n2", "n3", "n4", "n5"} {
+		total += len(r.Shards(n))
+	}
+	if total != shards {
+		t.Fatalf("shards assigned = %d, want %d", total, shards)
+	}
+	if r.ShardOwner(shards) != "" || r.ShardOwner(-1) != "" {
+		t.Fatalf("out-of-range shard has an owner")
+	}
+}
+
+// OwnershipFilter agrees with GetNode for every key
+func TestOwnershipFilter(t *testing.T) {
+	r := New()
+	r.AddNode("n1")
+	r.AddNodeWeighted("n2", 2)
+	r.AddNode("n3")
+
+	filters := map[Node]func(string) bool{}
+	for _, n := range []Node{"n1", "n2", "n3", "absent"} {
+		filters[n] = r.OwnershipFilter(n)
+	}
+
+	for i := 0; i < 50_000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		owner := r.GetNode(key)
+		for n, owns := range filters {
+			if owns(key) != (owner == n) {
+				t.Fatalf("%s: filter(%s) = %v, owner %s", key, n, owns(key), owner)
+			}
+		}
+	}
+}
+
+// A frozen ring routes like its source and ignores later mutations
+func TestFreeze(t *testing.T) {
+	r := New(WithCluster("frozen"))
+	r.AddNode("n1")
+	r.AddNodeWeighted("n2", 2)
+	r.AddNode("n3")
+	r.AddNode("n4")
+
+	f := r.Freeze()
+	for i := 0; i < 10_000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if got, want := f.GetNode(key), r.GetNode(key); got != want {
+			t.Fatalf("%s: frozen %s, ring %s", key, got, want)
+		}
+		if got, want := f.GetNodes(key, 3), r.GetNodes(key, 3); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("%s: frozen %v, ring %v", key, got, want)
+		}
+	}
+
+	r.RemoveNode("n2")
+	for i := 0; i < 1_000; i++ {
+		if f.GetNode(fmt.Sprintf("key-%d", i)) == "" {
+			t.Fatalf("frozen ring changed after RemoveNode")
+		}
+	}
+	if len(f.GetNodes("key", 5)) != 4 {
+		t.Fatalf("frozen ring lost a node after RemoveNode")
+	}
+}
+
+// Replica overlap is 1 for identical rings and ~0 for disjoint clusters
+func TestReplicaOverlap(t *testing.T) {
+	build := func(prefix string, count int) *HashRing {
+		r := New()
+		for i := 0; i < count; i++ {
+			r.AddNode(Node(fmt.Sprintf("%s%d", prefix, i)))
+		}
+		return r
+	}
+
+	keys := make([]string, 2_000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+
+	current := build("n", 5)
+	if got := ReplicaOverlap(current, build("n", 5), keys, 3); got != 1 {
+		t.Fatalf("identical rings overlap = %f, want 1", got)
+	}
+	if got := ReplicaOverlap(current, build("renamed", 5), keys, 3); got > 0.01 {
+		t.Fatalf("renamed cluster overlap = %f, want ~0", got)
+	}
+
+	// One extra node disturbs some replica sets, but far from all.
+	grown := ReplicaOverlap(current, build("n", 6), keys, 3)
+	t.Logf("overlap after adding a node: %.3f", grown)
+	if grown < 0.6 || grown >= 1 {
+		t.Fatalf("overlap after adding a node = %f", grown)
+	}
+}
+
+// Replica picks follow node weights
+func TestPickReplica(t *testing.T) {
+	r := New()
+	r.AddNodeWeighted("light", 1)
+	r.AddNodeWeighted("heavy", 3)
+
+	rng := rand.New(rand.NewSource(1))
+	counts := map[Node]int{}
+	const N = 40_000
+	for i := 0; i < N; i++ {
+		counts[r.PickReplica(fmt.Sprintf("key-%d", i%100), 2, rng)]++
+	}
+
+	share := float64(counts["heavy"]) / N
+	t.Logf("picks: %v (heavy share %.3f)", counts, share)
+	if share < 0.73 || share > 0.77 {
+		t.Fatalf("heavy share %.3f, want ~0.75", share)
+	}
+
+	if n := New().PickReplica("k", 2, rng); n != "" {
+		t.Fatalf("empty ring picked %q", n)
+	}
+}
+
+// Neighbors are exactly the nodes that inherit keys on removal
+func TestNeighbors(t *testing.T) {
+	r := New(WithVirtualNodes(3))
+	r.AddNode("n1")
+	r.AddNode("n2")
+	r.AddNode("n3")
+
+	neighbors := r.Neighbors("n1")
+	t.Logf("neighbors of n1: %v", neighbors)
+
+	before := map[string]Node{}
+	for i := 0; i < 20_000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		before[key] = r.GetNode(key)
+	}
+
+	r.RemoveNode("n1")
+	inherited := map[Node]bool{}
+	for key, owner := range before {
+		if owner == "n1" {
+			inherited[r.GetNode(key)] = true
+		}
+	}
+
+	var flow []Node
+	for n := range inherited {
+		flow = append(flow, n)
+	}
+	sortNodes(flow)
+	if fmt.Sprint(flow) != fmt.Sprint(neighbors) {
+		t.Fatalf("keys flowed to %v, Neighbors reported %v", flow, neighbors)
+	}
+
+	if got := r.Neighbors("n1"); len(got) != 0 {
+		t.Fatalf("removed node has neighbors %v", got)
+	}
+}
+
+// Absurd weights are rejected or clamped instead of exploding the ring
+func TestMaxVirtualNodesPerNode(t *testing.T) {
+	r := New(WithMaxVirtualNodesPerNode(1_000))
+	r.AddNode("n1")
+
+	err := r.AddNodeWeightedErr("huge", 100_000)
+	if !errors.Is(err, ErrTooManyVirtualNodes) {
+		t.Fatalf("AddNodeWeightedErr = %v, want ErrTooManyVirtualNodes", err)
+	}
+	if got := r.VirtualNodeCount("huge"); got != 0 {
+		t.Fatalf("rejected node placed %d vnodes", got)
+	}
+
+	if err := r.AddNodeWeightedErr("ok", 10); err != nil {
+		t.Fatalf("weight at the cap rejected: %v", err)
+	}
+
+	r.AddNodeWeighted("huge", math.MaxInt/2)
+	if got := r.VirtualNodeCount("huge"); got != 1_000 {
+		t.Fatalf("clamped node has %d vnodes, want 1000", got)
+	}
+	if len(r.ring) != 100+1_000+1_000 {
+		t.Fatalf("ring has %d points", len(r.ring))
+	}
+}
+
+// Draining over 4 steps moves about a quarter of the node's keys per step,
+// for plain and weighted nodes alike
+func TestDrain(t *testing.T) {
+	for _, weight := range []int{1, 4} {
+		r := New()
+		for i := 1; i <= 4; i++ {
+			r.AddNodeWeighted(Node(fmt.Sprintf("n%d", i)), weight)
+		}
+		var changes []Change
+		r.OnChange(func(c Change) { changes = append(changes, c) })
+
+		var owned []string
+		for i := 0; i < 40_000; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			if r.GetNode(key) == "n4" {
+				owned = append(owned, key)
+			}
+		}
+
+		d := r.Drain("n4", 4)
+		remaining := len(owned)
+		for step := 1; step <= 4; step++ {
+			more := d.Step()
+			if more != (step < 4) {
+				t.Fatalf("weight %d step %d: Step() = %v", weight, step, more)
+			}
+
+			still := 0
+			for _, key := range owned {
+				if r.GetNode(key) == "n4" {
+					still++
+				}
+			}
+			moved := remaining - still
+			remaining = still
+			share := float64(moved) / float64(len(owned))
+			t.Logf("weight %d step %d moved %d keys (%.2f of original)", weight, step, moved, share)
+			if share < 0.1 || share > 0.4 {
+				t.Fatalf("weight %d step %d moved %.2f of the node's keys, want ~0.25", weight, step, share)
+			}
+
+			// Intermediate steps are weight changes; only the last removes
+			c := changes[len(changes)-1]
+			if c.Node != "n4" || c.Added != (step < 4) {
+				t.Fatalf("weight %d step %d reported %+v", weight, step, c)
+			}
+			if step < 4 {
+				want := max(1, weight*(4-step)/4)
+				if w, _ := r.Weight("n4"); !r.ContainsNode("n4") || w != want {
+					t.Fatalf("weight %d step %d: weight %d, want %d", weight, step, w, want)
+				}
+			}
+		}
+		if len(changes) != 4 {
+			t.Fatalf("drain reported %d changes, want 4", len(changes))
+		}
+
+		if remaining != 0 || r.VirtualNodeCount("n4") != 0 {
+			t.Fatalf("node still owns %d keys after draining", remaining)
+		}
+		if _, ok := r.nodes["n4"]; ok {
+			t.Fatalf("drained node still registered")
+		}
+		if d.Step() || d.Remaining() != 0 {
+			t.Fatalf("finished drain did not stop")
+		}
+	}
+}
+
+// GroupByNode buckets every key under its primary owner
+func TestGroupByNode(t *testing.T) {
+	r := New()
+	r.AddNode("n1")
+	r.AddNodeWeighted("n2", 2)
+	r.AddNode("n3")
+
+	keys := make([]string, 5_000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+
+	groups := r.GroupByNode(keys)
+	total := 0
+	seen := map[string]bool{}
+	for n, bucket := range groups {
+		for _, k := range bucket {
+			if owner := r.GetNode(k); owner != n {
+				t.Fatalf("%s grouped under %s, owner %s", k, n, owner)
+			}
+			seen[k] = true
+		}
+		total += len(bucket)
+	}
+	if total != len(keys) || len(seen) != len(keys) {
+		t.Fatalf("grouped %d keys (%d distinct), want %d", total, len(seen), len(keys))
+	}
+
+	if got := New().GroupByNode(keys); len(got) != 0 {
+		t.Fatalf("empty ring grouped keys: %v", got)
+	}
+}
+
+// Each key claimed by a new node is sourced from its previous owner
+func TestClaimSources(t *testing.T) {
+	r := New()
+	r.AddNode("n1")
+	r.AddNode("n2")
+	r.AddNode("n3")
+
+	keys := make([]string, 10_000)
+	before := map[string]Node{}
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+		before[keys[i]] = r.GetNode(keys[i])
+	}
+
+	preview := r.ClaimSources("n4", keys)
+	r.AddNode("n4")
+	sources := r.ClaimSources("n4", keys)
+
+	claimed := 0
+	for _, k := range keys {
+		src, ok := sources[k]
+		if r.GetNode(k) != "n4" {
+			if ok {
+				t.Fatalf("%s reported claimed but owned by %s", k, r.GetNode(k))
+			}
+			continue
+		}
+		claimed++
+		if src != before[k] {
+			t.Fatalf("%s sourced from %s, previous owner %s", k, src, before[k])
+		}
+	}
+	if claimed == 0 || len(sources) != claimed {
+		t.Fatalf("claimed %d keys, %d sources", claimed, len(sources))
+	}
+	if fmt.Sprint(preview) != fmt.Sprint(sources) {
+		t.Fatalf("preview before the add differs from sources after it")
+	}
+}
+
+// Explicit points route exactly as placed
+func TestAddNodeWithPoints(t *testing.T) {
+	r := New()
+	r.AddNodeWithPoints("a", []uint32{1_000, 3_000})
+	r.AddNodeWithPoints("b", []uint32{2_000, 4_000, 3_000})
+
+	if got := r.VirtualNodeCount("b"); got != 2 {
+		t.Fatalf("b has %d points, want 2 (3000 is taken)", got)
+	}
+
+	cases := []struct {
+		point uint64
+		want  Node
+	}{
+		{0, "a"}, {1_000, "a"}, {1_001, "b"}, {2_000, "b"},
+		{2_500, "a"}, {3_500, "b"}, {4_000, "b"}, {4_001, "a"},
+	}
+	for _, c := range cases {
+		if got := r.ownerAt(c.point); got != c.want {
+			t.Fatalf("point %d owned by %s, want %s", c.point, got, c.want)
+		}
+	}
+
+	for i := 0; i < 1_000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		x := r.hash(key)
+		want := Node("a")
+		if (x > 1_000 && x <= 2_000) || (x > 3_000 && x <= 4_000) {
+			want = "b"
+		}
+		if got := r.GetNode(key); got != want {
+			t.Fatalf("%s (hash %d) routed to %s, want %s", key, x, got, want)
+		}
+	}
+
+	// Re-adding replaces the node's points
+	r.AddNodeWithPoints("a", []uint32{5_000})
+	if got := r.ownerAt(1_000); got != "b" {
+		t.Fatalf("old point still owned by %s", got)
+	}
+}
+
+// fnvHasher is an alternative Hasher for migration tests.
+type fnvHasher struct{}
+
+func (fnvHasher) Name() string { return "fnv1a" }
+
+func (fnvHasher) Sum32(b []byte) uint32 {
+	h := fnv.New32a()
+	h.Write(b)
+	return h.Sum32()
+}
+
+// shaHasher truncates SHA-256 to either point width, so 32-bit and 64-bit
+// rings can be compared with the same well-mixed hash.
+type shaHasher struct{}
+
+func (shaHasher) Name() string { return "sha256" }
+
+func (shaHasher) Sum32(b []byte) uint32 {
+	sum := sha256.Sum256(b)
+	return binary.BigEndian.Uint32(sum[:])
+}
+
+func (shaHasher) Sum64(b []byte) uint64 {
+	sum := sha256.Sum256(b)
+	return binary.BigEndian.Uint64(sum[:])
+}
+
+// A 64-bit ring routes keys within the same bounds as the default one
+func TestHasher64Balance(t *testing.T) {
+	r := New(WithHasher64(shaHasher{}))
+	r.AddNode("n1")
+	r.AddNode("n2")
+
+	count := make(map[Node]int)
+	const N = 200_000
+	for i := 0; i < N; i++ {
+		count[r.GetNode(fmt.Sprintf("key-%d", i))]++
+	}
+	c1 := float64(count["n1"]) / float64(N) * 100
+	c2 := float64(count["n2"]) / float64(N) * 100
+	t.Logf("Balance n1: %.2f%%, n2: %.2f%% (target 50/50)", c1, c2)
+	if math.Abs(c1-50) > 5 || math.Abs(c2-50) > 5 {
+		t.Fatalf("unbalanced: got %.2f/%.2f", c1, c2)
+	}
+
+	if got := r.HasherName(); got != "sha256" {
+		t.Fatalf("HasherName = %q", got)
+	}
+	ranges := r.OwnedRanges64("n1")
+	ranges = append(ranges, r.OwnedRanges64("n2")...)
+	top := uint64(0)
+	for _, rg := range ranges {
+		top = max(top, rg.End)
+	}
+	if top != math.MaxUint64 {
+		t.Fatalf("owned ranges end at %d, want the top of the 64-bit space", top)
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if r.GetNode(key) != r.Freeze().GetNode(key) {
+			t.Fatalf("frozen 64-bit ring disagrees on %q", key)
+		}
+	}
+}
+
+// Large clusters lose vnodes to collisions with 32-bit points but not 64-bit
+func TestHasher64Collisions(t *testing.T) {
+	// skipped counts vnode indices passed over because their point was taken
+	skipped := func(opts ...Option) int {
+		r := New(opts...)
+		for i := 0; i < 20; i++ {
+			r.AddNodeWeighted(Node(fmt.Sprintf("node-%d", i)), 200)
+		}
+		total := 0
+		for _, vns := range r.points {
+			total += vns[len(vns)-1].index + 1 - len(vns)
+		}
+		return total
+	}
+
+	// 400k points in 2^32 slots are expected to collide about 19 times
+	if got := skipped(WithHasher(shaHasher{})); got == 0 {
+		t.Fatalf("expected collisions with 32-bit points")
+	} else {
+		t.Logf("skipped vnodes with 32-bit points: %d", got)
+	}
+	if got := skipped(WithHasher64(shaHasher{})); got != 0 {
+		t.Fatalf("64-bit ring skipped %d vnodes", got)
+	}
+}
+
+// SetHasher rebuilds the ring exactly as a fresh ring with the new hasher
+func TestSetHasher(t *testing.T) {
+	r := New()
+	r.AddNode("n1")
+	r.AddNodeWeighted("n2", 2)
+	r.AddNodeWithMeta("n3", 1, "zone-b")
+	gen := r.Generation()
+
+	if got := r.HasherName(); got != "crc32" {
+		t.Fatalf("default HasherName = %q", got)
+	}
+
+	r.SetHasher(fnvHasher{})
+
+	if got := r.HasherName(); got != "fnv1a" {
+		t.Fatalf("HasherName after SetHasher = %q", got)
+	}
+	if r.Generation() <= gen {
+		t.Fatalf("generation not bumped")
+	}
+	if r.Zone("n3") != "zone-b" || r.VirtualNodeCount("n2") != 200 {
+		t.Fatalf("membership not preserved")
+	}
+
+	want := New(WithHasher(fnvHasher{}))
+	want.AddNode("n1")
+	want.AddNodeWeighted("n2", 2)
+	want.AddNode("n3")
+	if !bytes.Equal(r.CanonicalBytes(), want.CanonicalBytes()) {
+		t.Fatalf("placements differ from a fresh fnv ring")
+	}
+
+	// Every key whose owner changed lies in an arc ending at a delta point
+	for _, c := range r.LastDelta() {
+		if r.ownerAt(c.Point64) != c.New {
+			t.Fatalf("delta point %d: new owner %s, ring says %s", c.Point, c.New, r.ownerAt(c.Point64))
+		}
+	}
+}
+
+// A pinned key sticks to its node and falls back when the node is down
+func TestPin(t *testing.T) {
+	r := New(WithRebuildStrategy(CopyOnWrite))
+	r.AddNode("n1")
+	r.AddNode("n2")
+	r.AddNode("n3")
+
+	const key = "hot-key"
+	natural := r.GetNode(key)
+	var preferred Node = "n1"
+	if natural == preferred {
+		preferred = "n2"
+	}
+
+	gen := r.Generation()
+	r.Pin(key, preferred)
+	if got := r.GetNode(key); got != preferred {
+		t.Fatalf("pinned key routed to %s, want %s", got, preferred)
+	}
+	if r.Generation() == gen {
+		t.Fatalf("Pin did not advance the generation")
+	}
+
+	// Pins survive unrelated topology changes
+	r.AddNode("n4")
+	if got := r.GetNode(key); got != preferred {
+		t.Fatalf("pin lost after AddNode: got %s", got)
+	}
+
+	r.MarkDown(preferred)
+	if got, want := r.GetNode(key), r.ownerAt(r.hash(key)); got != want {
+		t.Fatalf("down pin routed to %s, want ring owner %s", got, want)
+	}
+	r.MarkUp(preferred)
+	if got := r.GetNode(key); got != preferred {
+		t.Fatalf("pin not restored after MarkUp: got %s", got)
+	}
+
+	// A pin to a removed node routes normally until the node returns
+	r.RemoveNode(preferred)
+	if got := r.GetNode(key); got == preferred {
+		t.Fatalf("pinned key routed to removed node")
+	}
+	r.AddNode(preferred)
+	if got := r.GetNode(key); got != preferred {
+		t.Fatalf("pin not restored after re-add: got %s", got)
+	}
+
+	r.Unpin(key)
+	if got, want := r.GetNode(key), r.ownerAt(r.hash(key)); got != want {
+		t.Fatalf("unpinned key routed to %s, want %s", got, want)
+	}
+	if got := r.GetNode("other"); got != r.ownerAt(r.hash("other")) {
+		t.Fatalf("unpinned keys are affected")
+	}
+}
+
+// UpdateWeight moves only the keys on the added or removed vnodes
+func TestUpdateWeight(t *testing.T) {
+	r := New()
+	r.AddNode("n1")
+	r.AddNode("n2")
+	r.AddNode("n3")
+
+	const N = 20_000
+	owners := func() []Node {
+		out := make([]Node, N)
+		for i := range out {
+			out[i] = r.GetNode(fmt.Sprintf("key-%d", i))
+		}
+		return out
+	}
+
+	before := owners()
+	if err := r.UpdateWeight("n2", 3); err != nil {
+		t.Fatalf("UpdateWeight up: %v", err)
+	}
+	grown := owners()
+	for i := range grown {
+		if grown[i] != before[i] && grown[i] != "n2" {
+			t.Fatalf("key-%d moved from %s to %s on weight increase", i, before[i], grown[i])
+		}
+	}
+	vns := r.points["n2"]
+	if len(vns) != 3*DefaultVirtualNodes || vns[0].index != 0 {
+		t.Fatalf("n2 has %d vnodes starting at %d", len(vns), vns[0].index)
+	}
+
+	if err := r.UpdateWeight("n2", 1); err != nil {
+		t.Fatalf("UpdateWeight down: %v", err)
+	}
+	shrunk := owners()
+	for i := range shrunk {
+		if shrunk[i] != before[i] {
+			t.Fatalf("key-%d owned by %s after restoring weight, was %s", i, shrunk[i], before[i])
+		}
+	}
+
+	if err := r.UpdateWeight("missing", 2); !errors.Is(err, ErrUnknownNode) {
+		t.Fatalf("unknown node: got %v, want ErrUnknownNode", err)
+	}
+	if err := r.UpdateWeight("n1", 0); err == nil {
+		t.Fatalf("zero weight accepted")
+	}
+	if err := r.UpdateWeight("n1", 1<<30); !errors.Is(err, ErrTooManyVirtualNodes) {
+		t.Fatalf("huge weight: got %v, want ErrTooManyVirtualNodes", err)
+	}
+	if r.VirtualNodeCount("n1") != DefaultVirtualNodes {
+		t.Fatalf("failed updates changed the ring")
+	}
+}
+
+// Nodes lists membership in sorted order alongside ContainsNode and Weight
+func TestNodesIntrospection(t *testing.T) {
+	r := New()
+	if got := r.Nodes(); len(got) != 0 {
+		t.Fatalf("empty ring lists %v", got)
+	}
+	r.AddNode("n3")
+	r.AddNodeWeighted("n1", 3)
+	r.AddNode("n2")
+	r.RemoveNode("n3")
+
+	if got, want := r.Nodes(), []Node{"n1", "n2"}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("Nodes = %v, want %v", got, want)
+	}
+	if !r.ContainsNode("n1") || r.ContainsNode("n3") {
+		t.Fatalf("ContainsNode disagrees with membership")
+	}
+	if w, ok := r.Weight("n1"); !ok || w != 3 {
+		t.Fatalf("Weight(n1) = %d, %v", w, ok)
+	}
+	if _, ok := r.Weight("n3"); ok {
+		t.Fatalf("Weight reports a removed node")
+	}
+}
+
+// Verify passes on healthy rings and names each kind of corruption
+func TestVerify(t *testing.T) {
+	build := func() *HashRing {
+		r := New()
+		r.AddNode("n1")
+		r.AddNodeWeighted("n2", 2)
+		r.AddNode("n3")
+		r.RemoveNode("n3")
+		return r
+	}
+	if err := build().Verify(); err != nil {
+		t.Fatalf("healthy ring: %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		corrupt func(r *HashRing)
+	}{
+		{"missing owner", func(r *HashRing) { delete(r.nodeMap, r.ring[5]) }},
+		{"unsorted", func(r *HashRing) { r.ring[3], r.ring[4] = r.ring[4], r.ring[3] }},
+		{"duplicate", func(r *HashRing) { r.ring[4] = r.ring[3] }},
+		{"removed owner", func(r *HashRing) { r.nodeMap[r.ring[0]] = "n3" }},
+		{"orphan point", func(r *HashRing) { r.nodeMap[r.ring[0]+1] = "n1" }},
+	}
+	for _, c := range cases {
+		r := build()
+		c.corrupt(r)
+		if err := r.Verify(); err == nil {
+			t.Fatalf("%s: corruption not detected", c.name)
+		} else {
+			t.Logf("%s: %v", c.name, err)
+		}
+	}
+}
+
+// Migrations list exactly the sample keys claimed or released by a node
+func TestNodeMigration(t *testing.T) {
+	r := New()
+	r.AddNode("n1")
+	r.AddNode("n2")
+
+	keys := make([]string, 2_000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	before := make(map[string]Node, len(keys))
+	for _, k := range keys {
+		before[k] = r.GetNode(k)
+	}
+
+	added := r.AddNodeWithMigration("n3", 1, keys)
+	if len(added) == 0 {
+		t.Fatalf("no keys migrated to the new node")
+	}
+	moved := make(map[string]bool)
+	for _, m := range added {
+		if m.To != "n3" || m.From != before[m.Key] {
+			t.Fatalf("unexpected migration %+v", m)
+		}
+		moved[m.Key] = true
+	}
+	for _, k := range keys {
+		if got := r.GetNode(k); !moved[k] && got != before[k] {
+			t.Fatalf("%s moved to %s but was not reported", k, got)
+		}
+	}
+
+	removed := r.RemoveNodeWithMigration("n3", keys)
+	if len(removed) != len(added) {
+		t.Fatalf("removal migrated %d keys, addition %d", len(removed), len(added))
+	}
+	for i, m := range removed {
+		if m.Key != added[i].Key || m.From != "n3" || m.To != before[m.Key] {
+			t.Fatalf("unexpected migration %+v", m)
+		}
+	}
+}
+
+// Bounded loads cap every node at ceil(factor * average) even for hot keys
+func TestBoundedLoad(t *testing.T) {
+	const factor, nodes, assignments = 1.25, 8, 4_000
+	r := New(WithBoundedLoad(factor))
+	for i := 0; i < nodes; i++ {
+		r.AddNode(Node(fmt.Sprintf("n%d", i)))
+	}
+
+	// Half the traffic hits a single hot key
+	for i := 0; i < assignments; i++ {
+		key := "hot"
+		if i%2 == 0 {
+			key = fmt.Sprintf("key-%d", i)
+		}
+		r.Inc(r.GetNode(key))
+	}
+
+	limit := int64(math.Ceil(factor * assignments / nodes))
+	for i := 0; i < nodes; i++ {
+		n := Node(fmt.Sprintf("n%d", i))
+		if got := r.Load(n); got > limit {
+			t.Fatalf("%s carries %d assignments, bound is %d", n, got, limit)
+		}
+	}
+
+	// Releasing load lets the hot key return to its natural owner
+	owner := r.ownerAt(r.hash("hot"))
+	for r.Load(owner) > 0 {
+		r.Dec(owner)
+	}
+	if got := r.GetNode("hot"); got != owner {
+		t.Fatalf("hot key routed to %s after its owner drained, want %s", got, owner)
+	}
+
+	// Without the option, load counters do not affect routing
+	plain := New()
+	plain.AddNode("a")
+	plain.AddNode("b")
+	first := plain.GetNode("hot")
+	for i := 0; i < 100; i++ {
+		plain.Inc(first)
+	}
+	if plain.GetNode("hot") != first {
+		t.Fatalf("load changed routing on an unbounded ring")
+	}
+}
+
+// Load bounds GetNode only: migrations and clones follow the ring owner
+func TestBoundedLoadInternalOwners(t *testing.T) {
+	r := New(WithBoundedLoad(1), WithRebuildStrategy(CopyOnWrite))
+	r.AddNode("a")
+	r.AddNode("b")
+
+	keys := make([]string, 500)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	owner := r.ownerAt(r.hash(keys[0]))
+	for i := 0; i < 100; i++ {
+		r.Inc(owner)
+	}
+	if r.GetNode(keys[0]) == owner {
+		t.Fatalf("overloaded owner still returned by GetNode under CopyOnWrite")
+	}
+
+	for _, m := range r.AddNodeWithMigration("c", 1, keys) {
+		if m.To != "c" {
+			t.Fatalf("%s reported moving %s -> %s, but only c joined", m.Key, m.From, m.To)
+		}
+	}
+	if c := r.clone(); c.Load(owner) != 0 {
+		t.Fatalf("clone shares the ring's load")
+	}
+}
+
+// Spread replicas cover distinct zones first and fall back to shared ones
+func TestGetNodesSpread(t *testing.T) {
+	r := New()
+	for i := 0; i < 9; i++ {
+		r.AddNodeWithMeta(Node(fmt.Sprintf("n%d", i)), 1, fmt.Sprintf("rack-%d", i%3))
+	}
+
+	for i := 0; i < 200; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		got := r.GetNodesSpread(key, 3)
+		if len(got) != 3 || got[0] != r.GetNode(key) {
+			t.Fatalf("%s: got %v, want 3 replicas led by the primary", key, got)
+		}
+		racks := make(map[string]bool)
+		for _, n := range got {
+			racks[r.Zone(n)] = true
+		}
+		if len(racks) != 3 {
+			t.Fatalf("%s: replicas %v share a rack", key, got)
+		}
+
+		// Five replicas over three racks still returns five distinct nodes
+		more := r.GetNodesSpread(key, 5)
+		if len(more) != 5 || fmt.Sprint(more[:3]) != fmt.Sprint(got) {
+			t.Fatalf("%s: fallback got %v, want %v extended to 5", key, more, got)
+		}
+		seen := make(map[Node]bool)
+		for _, n := range more {
+			if seen[n] {
+				t.Fatalf("%s: duplicate replica in %v", key, more)
+			}
+			seen[n] = true
+		}
+	}
+
+	if got := r.GetNodesSpread("key", 20); len(got) != 9 {
+		t.Fatalf("got %d replicas, want all 9 nodes", len(got))
+	}
+}
+
+// Rebalance flattens the load of a small ring with few vnodes
+func TestRebalance(t *testing.T) {
+	r := New(WithVirtualNodes(10))
+	r.AddNode("n1")
+	r.AddNode("n2")
+	r.AddNode("n3")
+	r.AddNode("n4")
+
+	// stddev measures load spread over keys distinct from Rebalance's sample
+	stddev := func() float64 {
+		const N = 100_000
+		count := make(map[Node]int)
+		for i := 0; i < N; i++ {
+			count[r.GetNode(fmt.Sprintf("key-%d", i))]++
+		}
+		mean := float64(N) / 4
+		var sum float64
+		for _, n := range []Node{"n1", "n2", "n3", "n4"} {
+			d := float64(count[n]) - mean
+			sum += d * d
+		}
+		return math.Sqrt(sum/4) / mean
+	}
+
+	before := stddev()
+	gen := r.Generation()
+	prev := r.clone()
+	r.Rebalance(50_000)
+	after := stddev()
+	t.Logf("relative load stddev before %.3f, after %.3f", before, after)
+
+	if after >= before {
+		t.Fatalf("Rebalance did not reduce spread: %.3f -> %.3f", before, after)
+	}
+	if r.Generation() == gen {
+		t.Fatalf("Rebalance did not advance the generation")
+	}
+	if w, _ := r.Weight("n1"); w != 1 {
+		t.Fatalf("Rebalance changed weight to %d", w)
+	}
+
+	// LastDelta is the net change, not every round's resizes
+	delta := r.LastDelta()
+	if len(delta) == 0 || len(delta) > len(prev.ring)+len(r.ring) {
+		t.Fatalf("LastDelta has %d changes for rings of %d and %d points", len(delta), len(prev.ring), len(r.ring))
+	}
+	for _, c := range delta {
+		if c.Old != prev.ownerAt(c.Point64) || c.New != r.ownerAt(c.Point64) || c.Old == c.New {
+			t.Fatalf("delta %+v does not match the placements before and after", c)
+		}
+	}
+	if err := r.Verify(); err != nil {
+		t.Fatalf("Verify after Rebalance: %v", err)
+	}
+}
+
+// Distribution sums exact arc lengths, wrap-around included
+func TestDistribution(t *testing.T) {
+	r := New()
+	if d := r.Distribution(); len(d) != 0 || r.Imbalance() != 0 {
+		t.Fatalf("empty ring: %v, imbalance %v", d, r.Imbalance())
+	}
+
+	// Points split the 2^32 space into arcs of known length
+	r.AddNodeWithPoints("a", []uint32{1 << 30, 3 << 30})
+	r.AddNodeWithPoints("b", []uint32{2 << 30})
+	d := r.Distribution()
+	if d["a"] != 0.75 || d["b"] != 0.25 {
+		t.Fatalf("Distribution = %v, want a=0.75 b=0.25", d)
+	}
+	if got := r.Imbalance(); got != 3 {
+		t.Fatalf("Imbalance = %v, want 3", got)
+	}
+
+	// Hashed rings sum to one and match sampled key ownership
+	h := New(WithVirtualNodes(200))
+	for i := 0; i < 4; i++ {
+		h.AddNode(Node(fmt.Sprintf("n%d", i)))
+	}
+	const N = 200_000
+	count := make(map[Node]int)
+	for i := 0; i < N; i++ {
+		count[h.GetNode(fmt.Sprintf("key-%d", i))]++
+	}
+	total := 0.0
+	for n, share := range h.Distribution() {
+		total += share
+		if sampled := float64(count[n]) / N; math.Abs(sampled-share) > 0.02 {
+			t.Fatalf("%s: share %.4f, sampled %.4f", n, share, sampled)
+		}
+	}
+	if math.Abs(total-1) > 1e-9 {
+		t.Fatalf("shares sum to %v", total)
+	}
+	if imb := h.Imbalance(); imb < 1 || imb > 1.5 {
+		t.Fatalf("Imbalance = %v", imb)
+	}
+}
+
+// Excluded nodes are skipped while the remaining replicas keep their order
+func TestGetNodesExcluding(t *testing.T) {
+	r := New()
+	for i := 0; i < 5; i++ {
+		r.AddNode(Node(fmt.Sprintf("n%d", i)))
+	}
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		all := r.GetNodes(key, 5)
+		down := map[Node]struct{}{all[0]: {}, all[2]: {}}
+
+		got := r.GetNodesExcluding(key, 3, down)
+		if want := []Node{all[1], all[3], all[4]}; fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("%s: got %v, want %v", key, got, want)
+		}
+		if got := r.GetNodesExcluding(key, 5, down); len(got) != 3 {
+			t.Fatalf("%s: got %v, want the 3 healthy nodes", key, got)
+		}
+		if got := r.GetNodesExcluding(key, 3, nil); fmt.Sprint(got) != fmt.Sprint(all[:3]) {
+			t.Fatalf("%s: no exclusions got %v, want %v", key, got, all[:3])
+		}
+	}
+}
+
+// Sharded snapshots agree with the live ring across refreshes under -race
+func TestShardedCopyOnWrite(t *testing.T) {
+	r := New(WithRebuildStrategy(ShardedCopyOnWrite), WithSnapshotShards(4))
+	ref := New()
+	for i := 0; i < 4; i++ {
+		r.AddNode(Node(fmt.Sprintf("n%d", i)))
+		ref.AddNode(Node(fmt.Sprintf("n%d", i)))
+	}
+	if len(r.snapShards) != 4 {
+		t.Fatalf("got %d snapshot shards, want 4", len(r.snapShards))
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if n := r.GetNode(fmt.Sprintf("key-%d-%d", g, i)); n == "" {
+					t.Errorf("empty owner during refresh")
+					return
+				}
+			}
+		}(g)
+	}
+	for i := 0; i < 50; i++ {
+		r.AddNode("tmp")
+		r.RemoveNode("tmp")
+	}
+	close(stop)
+	wg.Wait()
+
+	// Once a mutation returns, every shard serves it
+	r.AddNode("n4")
+	ref.AddNode("n4")
+	for i := 0; i < 1_000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if got, want := r.GetNode(key), ref.GetNode(key); got != want {
+			t.Fatalf("%s: sharded ring returned %s, want %s", key, got, want)
+		}
+	}
+}
+
+// sha1Hasher takes the first four bytes of SHA-1, as Python rings often do.
+type sha1Hasher struct{}
+
+func (sha1Hasher) Sum32(b []byte) uint32 {
+	sum := sha1.Sum(b)
+	return binary.BigEndian.Uint32(sum[:])
+}
+
+// A custom identity format reproduces another implementation's placement
+func TestVirtualNodeFormat(t *testing.T) {
+	format := func(n Node, i int) string { return fmt.Sprintf("%s:%d", n, i) }
+	r := New(WithHasher(sha1Hasher{}), WithVirtualNodeFormat(format), WithVirtualNodes(20))
+	r.AddNode("cache-a")
+	r.AddNode("cache-b")
+
+	for _, n := range []Node{"cache-a", "cache-b"} {
+		for _, v := range r.points[n] {
+			sum := sha1.Sum([]byte(format(n, v.index)))
+			if want := uint64(binary.BigEndian.Uint32(sum[:])); v.point != want {
+				t.Fatalf("%s vnode %d at %d, want %d", n, v.index, v.point, want)
+			}
+		}
+	}
+
+	// The default identity is unchanged
+	def, dashed := New(), New(WithVirtualNodeFormat(func(n Node, i int) string { return fmt.Sprintf("%s-%d", n, i) }))
+	def.AddNode("n1")
+	dashed.AddNode("n1")
+	if !bytes.Equal(def.CanonicalBytes(), dashed.CanonicalBytes()) {
+		t.Fatalf("explicit <node>-<index> format differs from the default")
+	}
+}
+
+// Migration callbacks fire for exactly the tracked keys a new node captures
+func TestOnKeyMigration(t *testing.T) {
+	r := New()
+	r.AddNode("n1")
+	r.AddNode("n2")
+
+	keys := make([]string, 1_000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	before := make(map[string]Node)
+	for _, k := range keys {
+		before[k] = r.GetNode(k)
+	}
 
-	if _, exists := r.nodes["n1"]; exists {
-		t.Fatalf("node n1 not removed from nodes map")
+	got := make(map[string][2]Node)
+	r.OnKeyMigration(func(key string, from, to Node) {
+		if r.GetNode(key) != to {
+			t.Errorf("%s: callback reports owner %s, ring says %s", key, to, r.GetNode(key))
+		}
+		got[key] = [2]Node{from, to}
+	})
+	r.TrackKeys(keys...)
+	r.UntrackKeys(keys[0])
+
+	r.AddNode("n3")
+	want := 0
+	for _, k := range keys[1:] {
+		if r.GetNode(k) != "n3" {
+			if _, ok := got[k]; ok {
+				t.Fatalf("%s reported but did not move", k)
+			}
+			continue
+		}
+		want++
+		if got[k] != [2]Node{before[k], "n3"} {
+			t.Fatalf("%s: got %v, want %s -> n3", k, got[k], before[k])
+		}
+	}
+	if want == 0 || len(got) != want {
+		t.Fatalf("got %d callbacks, want %d", len(got), want)
+	}
+	if _, ok := got[keys[0]]; ok {
+		t.Fatalf("untracked key reported")
 	}
 
-	// Ensure no ring point maps to removed node
-	for _, p := range r.ring {
-		if r.nodeMap[p] == "n1" {
-			t.Fatalf("found virtual node of removed node n1")
+	// Pins and load do not change ring ownership
+	r.Pin(keys[1], "n1")
+	r.Pin(keys[2], "n2")
+	if len(got) != want {
+		t.Fatalf("pinning fired migrations: %d callbacks, want %d", len(got), want)
+	}
+	r.Unpin(keys[1])
+	r.Unpin(keys[2])
+
+	// Removing the node moves the captured keys back
+	clear(got)
+	r.RemoveNode("n3")
+	if len(got) != want {
+		t.Fatalf("got %d callbacks on removal, want %d", len(got), want)
+	}
+	for k, m := range got {
+		if m != [2]Node{"n3", before[k]} {
+			t.Fatalf("%s: got %v on removal", k, m)
 		}
 	}
 }
 
-// Weights approximate 1:2 ratio
-func TestWeighted(t *testing.T) {
-	r := New()
-	r.AddNodeWeighted("n1", 1)
-	r.AddNodeWeighted("n2", 2)
+// 64-bit rings report points through the 64-bit variants only
+func TestHasher64PointAPIs(t *testing.T) {
+	r := New(WithHasher64(shaHasher{}))
+	r.AddNodeWithPoints64("a", []uint64{1 << 40, 3 << 40})
+	r.AddNodeWithPoints64("b", []uint64{2 << 40})
 
-	count := make(map[string]int)
-	const N = 1_000_000
+	for _, c := range r.LastDelta() {
+		if c.Point != 0 || c.Point64 != 2<<40 || c.New != "b" || c.Old != "a" {
+			t.Fatalf("64-bit delta = %+v", c)
+		}
+	}
+	if got := r.OwnedRanges("b"); got != nil {
+		t.Fatalf("OwnedRanges on a 64-bit ring = %v, want nil", got)
+	}
+	if got := r.OwnedRanges64("b"); len(got) != 1 || got[0] != (Range64{Start: 1<<40 + 1, End: 2 << 40}) {
+		t.Fatalf("OwnedRanges64(b) = %v", got)
+	}
+	key := "k"
+	owner, points := r.OwnerFootprint(key)
+	if owner != r.GetNode(key) || points != nil {
+		t.Fatalf("OwnerFootprint on a 64-bit ring = %s, %v", owner, points)
+	}
+	if _, wide := r.OwnerFootprint64(key); len(wide) != r.VirtualNodeCount(owner) {
+		t.Fatalf("OwnerFootprint64 returned %d points", len(wide))
+	}
 
-	for i := 0; i < N; i++ {
-		n := string(r.GetNode(fmt.Sprintf("key-%d", i)))
-		count[n]++
+	// Default rings keep 32-bit points and cannot place above them
+	d := New()
+	d.AddNodeWithPoints64("a", []uint64{1_000, 1 << 40})
+	if got := d.VirtualNodeCount("a"); got != 1 {
+		t.Fatalf("default ring placed %d points, want 1", got)
 	}
+	if c := d.LastDelta(); len(c) != 1 || c[0].Point != 1_000 || c[0].Point64 != 1_000 {
+		t.Fatalf("default delta = %+v", c)
+	}
+}
 
-	p1 := float64(count["n1"]) / float64(N) * 100
-	p2 := float64(count["n2"]) / float64(N) * 100
-	t.Logf("Weighted n1: %.2f%%, n2: %.2f%% (target ~33.3/66.7)", p1, p2)
+// Removing a node that is not on the ring changes nothing and notifies nobody
+func TestRemoveAbsentNode(t *testing.T) {
+	r := New()
+	r.AddNode("a")
+	r.AddNode("b")
 
-	if math.Abs(p1-33.3) > 5 || math.Abs(p2-66.7) > 5 {
-		t.Fatalf("bad weight distribution: %.2f/%.2f", p1, p2)
+	var changes []Change
+	r.OnChange(func(c Change) { changes = append(changes, c) })
+	gen, delta := r.Generation(), r.LastDelta()
+
+	r.RemoveNode("ghost")
+	if moved := r.RemoveNodeWithMigration("ghost", []string{"k1", "k2"}); moved != nil {
+		t.Fatalf("RemoveNodeWithMigration(ghost) = %v", moved)
+	}
+	if r.Generation() != gen {
+		t.Fatalf("generation moved from %d to %d", gen, r.Generation())
+	}
+	if len(changes) != 0 {
+		t.Fatalf("listeners saw %v", changes)
+	}
+	if got := r.LastDelta(); len(got) != len(delta) {
+		t.Fatalf("LastDelta replaced: %d -> %d changes", len(delta), len(got))
 	}
 }
 
@@ -93,6 +1701,195 @@ func TestReplicas(t *testing.T) {
 	t.Logf("Replicas: %v", nodes)
 }
 
+// NewReplicated routes identically to a manually assembled ring
+func TestNewReplicated(t *testing.T) {
+	nodes := []Node{"n1", "n2", "n3", "n4"}
+	r := NewReplicated(nodes, 3, 50)
+
+	m := New(WithVirtualNodes(50))
+	for _, n := range nodes {
+		m.AddNode(n)
+	}
+
+	for i := 0; i < 10_000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		got := r.Route(key)
+		want := m.GetNodes(key, 3)
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("%s: Route = %v, manual = %v", key, got, want)
+		}
+	}
+}
+
+// Replica-set membership under heavy weighting tracks weighted sampling
+// without replacement.
+//
+// Tolerance: ±5 percentage points for the heavy node and ±8 for light nodes,
+// which carry only 100 vnodes each and so see more placement noise.
+func TestWeightedReplicaMembership(t *testing.T) {
+	const N = 200_000
+
+	// 1:10 pair: primaries follow the weight ratio, replicas cover both
+	pair := New()
+	pair.AddNodeWeighted("light", 1)
+	pair.AddNodeWeighted("heavy", 10)
+
+	heavy := 0
+	for i := 0; i < N; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if pair.GetNode(key) == "heavy" {
+			heavy++
+		}
+		if nodes := pair.GetNodes(key, 2); unique(nodes) != 2 {
+			t.Fatalf("%s: replica set %v not both nodes", key, nodes)
+		}
+	}
+	share := float64(heavy) / N * 100
+	t.Logf("1:10 pair heavy primary share: %.2f%% (target 90.91)", share)
+	if math.Abs(share-100.0*10/11) > 5 {
+		t.Fatalf("heavy primary share %.2f%% outside tolerance", share)
+	}
+
+	// One heavy node and four light nodes, replicas = 2
+	r := New()
+	r.AddNodeWeighted("h", 10)
+	for i := 0; i < 4; i++ {
+		r.AddNode(Node(fmt.Sprintf("l%d", i)))
+	}
+
+	count := make(map[Node]int)
+	for i := 0; i < N; i++ {
+		for _, n := range r.GetNodes(fmt.Sprintf("key-%d", i), 2) {
+			count[n]++
+		}
+	}
+
+	// P(h in set) = 10/14 + 4/14 * 10/13; lights split the remaining slot
+	wantHeavy := 10.0/14 + 4.0/14*10.0/13
+	wantLight := (2 - wantHeavy) / 4
+
+	for n, c := range count {
+		got := float64(c) / N
+		want, tol := wantLight, 0.08
+		if n == "h" {
+			want, tol = wantHeavy, 0.05
+		}
+		t.Logf("Membership %s: %.3f (target %.3f)", n, got, want)
+		if math.Abs(got-want) > tol {
+			t.Fatalf("%s membership %.3f outside %.3f±%.2f", n, got, want, tol)
+		}
+	}
+}
+
+// Hybrid fanout splits replicas between the primary's zone and others
+func TestGetNodesZoneHybrid(t *testing.T) {
+	r := New()
+	zones := map[Node]string{
+		"a1": "zone-a", "a2": "zone-a", "a3": "zone-a",
+		"b1": "zone-b", "b2": "zone-b",
+		"c1": "zone-c",
+	}
+	for n, z := range zones {
+		r.AddNodeWithMeta(n, 1, z)
+	}
+
+	for i := 0; i < 1_000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if r.GetNode(key) == "c1" {
+			continue // checked below
+		}
+		nodes := r.GetNodesZoneHybrid(key, 2, 2)
+
+		if len(nodes) != 4 || unique(nodes) != 4 {
+			t.Fatalf("%s: expected 4 distinct nodes, got %v", key, nodes)
+		}
+		if nodes[0] != r.GetNode(key) {
+			t.Fatalf("%s: first replica %s is not primary %s", key, nodes[0], r.GetNode(key))
+		}
+
+		home := zones[nodes[0]]
+		if zones[nodes[1]] != home {
+			t.Fatalf("%s: second local replica %s outside %s", key, nodes[1], home)
+		}
+		for _, n := range nodes[2:] {
+			if zones[n] == home {
+				t.Fatalf("%s: remote replica %s in home zone %s", key, n, home)
+			}
+		}
+	}
+
+	// zone-c has one node, so its local group comes back short
+	for i := 0; ; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if r.GetNode(key) != "c1" {
+			continue
+		}
+		nodes := r.GetNodesZoneHybrid(key, 2, 2)
+		if len(nodes) != 3 || nodes[0] != "c1" {
+			t.Fatalf("%s: expected [c1 + 2 remote], got %v", key, nodes)
+		}
+		break
+	}
+}
+
+// Ambiguous concatenations route independently
+func TestGetNodeComposite(t *testing.T) {
+	r := New()
+	r.AddNode("n1")
+	r.AddNode("n2")
+
+	if r.hashParts([]string{"t1", "k"}) == r.hashParts([]string{"t", "1k"}) {
+		t.Fatalf("(t1,k) and (t,1k) hash identically")
+	}
+
+	// Over many ambiguous pairs, independent hashing splits owners ~50%
+	differ := 0
+	const N = 2_000
+	for i := 0; i < N; i++ {
+		k := fmt.Sprintf("%d", i)
+		if r.GetNodeComposite("t1", k) != r.GetNodeComposite("t", "1"+k) {
+			differ++
+		}
+	}
+	if differ < N/4 {
+		t.Fatalf("composite keys correlated: only %d/%d differ", differ, N)
+	}
+
+	nodes := r.GetNodesComposite(2, "t1", "k")
+	if len(nodes) != 2 || nodes[0] != r.GetNodeComposite("t1", "k") {
+		t.Fatalf("GetNodesComposite = %v, primary %s", nodes, r.GetNodeComposite("t1", "k"))
+	}
+}
+
+// Replica sets stay complete and distinct under extreme weighting
+func TestGetNodesUniqueHeavyWeights(t *testing.T) {
+	r := New()
+	r.AddNodeWeighted("light-a", 1)
+	r.AddNodeWeighted("heavy", 50)
+	r.AddNodeWeighted("light-b", 1)
+
+	for i := 0; i < 100_000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		nodes := r.GetNodes(key, 3)
+		if len(nodes) != 3 || unique(nodes) != 3 {
+			t.Fatalf("%s: expected 3 distinct nodes, got %v", key, nodes)
+		}
+	}
+}
+
+// A node without ring points cannot stall replica selection
+func TestGetNodesZeroWeight(t *testing.T) {
+	r := New()
+	r.AddNode("n1")
+	r.AddNode("n2")
+	r.AddNodeWeighted("empty", 0)
+
+	nodes := r.GetNodes("key", 3)
+	if len(nodes) != 2 || unique(nodes) != 2 {
+		t.Fatalf("expected the 2 placed nodes, got %v", nodes)
+	}
+}
+
 // Replication caps at available nodes
 func TestReplicaCap(t *testing.T) {
 	r := New()
@@ -105,6 +1902,42 @@ func TestReplicaCap(t *testing.T) {
 	}
 }
 
+// Ownership matches the primary and the replica preference list
+func TestIsOwner(t *testing.T) {
+	r := New()
+	for i := 0; i < 5; i++ {
+		r.AddNode(Node(fmt.Sprintf("n%d", i)))
+	}
+
+	for i := 0; i < 1_000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+
+		primary := r.GetNode(key)
+		if !r.IsOwner(key, primary, 1) {
+			t.Fatalf("%s: primary %s not reported as owner", key, primary)
+		}
+
+		replicas := r.GetNodes(key, 3)
+		in := make(map[Node]bool)
+		for _, n := range replicas {
+			in[n] = true
+			if !r.IsOwner(key, n, 3) {
+				t.Fatalf("%s: replica %s not reported as owner", key, n)
+			}
+		}
+
+		for j := 0; j < 5; j++ {
+			n := Node(fmt.Sprintf("n%d", j))
+			if !in[n] && r.IsOwner(key, n, 3) {
+				t.Fatalf("%s: non-replica %s reported as owner", key, n)
+			}
+			if n != primary && r.IsOwner(key, n, 1) {
+				t.Fatalf("%s: non-primary %s reported as primary owner", key, n)
+			}
+		}
+	}
+}
+
 // Race-safety & distribution under concurrent access
 func TestConcurrent(t *testing.T) {
 	r := New()
@@ -154,6 +1987,98 @@ func TestConcurrent(t *testing.T) {
 	}
 }
 
+// Concurrent lookups racing with membership changes on the default
+// (locked) ring. Run with -race to verify synchronization.
+func TestConcurrentMutation(t *testing.T) {
+	r := New()
+	r.AddNode("n1")
+	r.AddNode("n2")
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(gid int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				key := fmt.Sprintf("key-%d-%d", gid, i)
+				if n := r.GetNode(key); n == "" {
+					t.Errorf("empty owner for %s", key)
+					return
+				}
+				_ = r.GetNodes(key, 2)
+			}
+		}(g)
+	}
+
+	for i := 0; i < 50; i++ {
+		r.AddNode("n3")
+		r.RemoveNode("n3")
+	}
+	close(stop)
+	wg.Wait()
+}
+
+// Both rebuild strategies route identically and survive concurrent
+// mutation. Run with -race.
+func TestRebuildStrategies(t *testing.T) {
+	build := func(s RebuildStrategy) *HashRing {
+		r := New(WithRebuildStrategy(s))
+		r.AddNode("n1")
+		r.AddNodeWeighted("n2", 2)
+		r.AddNode("n3")
+		r.RemoveNode("n1")
+		r.AddNode("n4")
+		return r
+	}
+
+	inPlace, cow := build(InPlace), build(CopyOnWrite)
+	for i := 0; i < 10_000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if a, b := inPlace.GetNode(key), cow.GetNode(key); a != b {
+			t.Fatalf("%s: InPlace %s, CopyOnWrite %s", key, a, b)
+		}
+		if a, b := inPlace.GetNodes(key, 2), cow.GetNodes(key, 2); fmt.Sprint(a) != fmt.Sprint(b) {
+			t.Fatalf("%s: InPlace %v, CopyOnWrite %v", key, a, b)
+		}
+	}
+
+	for _, r := range []*HashRing{inPlace, cow} {
+		var wg sync.WaitGroup
+		stop := make(chan struct{})
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+			go func(gid int) {
+				defer wg.Done()
+				for i := 0; ; i++ {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					if r.GetNode(fmt.Sprintf("key-%d-%d", gid, i)) == "" {
+						t.Errorf("empty owner")
+						return
+					}
+					_ = r.GetNodes(fmt.Sprintf("key-%d", i), 2)
+				}
+			}(g)
+		}
+		for i := 0; i < 20; i++ {
+			r.AddNode("n5")
+			r.RemoveNode("n5")
+		}
+		close(stop)
+		wg.Wait()
+	}
+}
+
 // ---------------- Benchmarks ----------------
 
 // BenchmarkGetNode measures:
@@ -178,6 +2103,185 @@ func BenchmarkGetNode(b *testing.B) {
 	}
 }
 
+// BenchmarkGetNodeLocking compares lookups on the default locked ring with
+// a ring built WithoutLocking, isolating the RWMutex overhead per lookup.
+func BenchmarkGetNodeLocking(b *testing.B) {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+
+	for _, bc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"locked", nil},
+		{"unlocked", []Option{WithoutLocking()}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			r := New(bc.opts...)
+			for i := 0; i < 10; i++ {
+				r.AddNode(Node(fmt.Sprintf("n%d", i)))
+			}
+
+			b.ResetTimer()
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				_ = r.GetNode(keys[i%len(keys)])
+			}
+		})
+	}
+}
+
+// BenchmarkGetNodeParallel compares lookups from every P on the locked
+// ring with the copy-on-write strategies, whose readers share no mutex.
+// Run with -cpu to vary GOMAXPROCS.
+func BenchmarkGetNodeParallel(b *testing.B) {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+
+	for _, bc := range []struct {
+		name     string
+		strategy RebuildStrategy
+	}{
+		{"rwmutex", InPlace},
+		{"cow", CopyOnWrite},
+		{"sharded", ShardedCopyOnWrite},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			r := New(WithRebuildStrategy(bc.strategy))
+			for i := 0; i < 10; i++ {
+				r.AddNode(Node(fmt.Sprintf("n%d", i)))
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					_ = r.GetNode(keys[i%len(keys)])
+					i++
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkGetNodeParallelChurn compares CopyOnWrite with ShardedCopyOnWrite
+// while a background writer cycles a node in and out, so the cost of
+// publishing one copy per shard is measured next to the read-side gain.
+func BenchmarkGetNodeParallelChurn(b *testing.B) {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+
+	for _, bc := range []struct {
+		name     string
+		strategy RebuildStrategy
+	}{
+		{"cow", CopyOnWrite},
+		{"sharded", ShardedCopyOnWrite},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			r := New(WithRebuildStrategy(bc.strategy))
+			for i := 0; i < 10; i++ {
+				r.AddNode(Node(fmt.Sprintf("n%d", i)))
+			}
+
+			stop := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for {
+					select {
+					case <-stop:
+						return
+					case <-time.After(time.Millisecond):
+					}
+					r.AddNode("churn")
+					r.RemoveNode("churn")
+				}
+			}()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					_ = r.GetNode(keys[i%len(keys)])
+					i++
+				}
+			})
+			b.StopTimer()
+			close(stop)
+			<-done
+		})
+	}
+}
+
+// BenchmarkGetNodeFrozen compares lookups on the locked ring with its
+// frozen copy, which needs neither a lock nor a map access.
+func BenchmarkGetNodeFrozen(b *testing.B) {
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+
+	r := New()
+	for i := 0; i < 10; i++ {
+		r.AddNode(Node(fmt.Sprintf("n%d", i)))
+	}
+	f := r.Freeze()
+
+	b.Run("locked", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = r.GetNode(keys[i%len(keys)])
+		}
+	})
+	b.Run("frozen", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = f.GetNode(keys[i%len(keys)])
+		}
+	})
+}
+
+// BenchmarkAddRemoveChurn measures allocations of a high-churn lifecycle:
+// building a 10-node ring, then cycling a node in and out, with and without
+// preallocated capacity.
+func BenchmarkAddRemoveChurn(b *testing.B) {
+	nodes := make([]Node, 10)
+	for i := range nodes {
+		nodes[i] = Node(fmt.Sprintf("n%d", i))
+	}
+
+	for _, bc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"default", nil},
+		{"preallocated", []Option{WithPreallocatedCapacity(11 * DefaultVirtualNodes)}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				r := New(bc.opts...)
+				for _, n := range nodes {
+					r.AddNode(n)
+				}
+				for j := 0; j < 10; j++ {
+					r.AddNode("churn")
+					r.RemoveNode("churn")
+				}
+			}
+		})
+	}
+}
+
 // BenchmarkGetNodes measures:
 // - cost of replica selection
 // - overhead of deduplication across virtual nodes
@@ -198,6 +2302,27 @@ func BenchmarkGetNodes(b *testing.B) {
 	}
 }
 
+// BenchmarkAddNodeWeighted measures:
+// - cost of placing a heavily weighted node on the ring
+// - per-vnode allocations of the string identity vs the integer mix
+func BenchmarkAddNodeWeighted(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"string", nil},
+		{"mixed", []Option{WithMixedVirtualNodes()}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				r := New(bc.opts...)
+				r.AddNodeWeighted("n1", 10)
+			}
+		})
+	}
+}
+
 func unique(nodes []Node) int {
 	seen := make(map[Node]struct{})
 	for _, n := range nodes {
@@ -0,0 +1,36 @@
+// Package hashringtest provides helpers for testing and benchmarking code
+// built on package hashring.
+package hashringtest
+
+import (
+	"strconv"
+	"unsafe"
+)
+
+// KeyGen produces the deterministic key stream "<prefix>0", "<prefix>1", ...
+// without allocating, so benchmarks measure ring cost rather than key
+// formatting cost.
+//
+// The string returned by Next aliases an internal buffer and is only valid
+// until the following call; copy it (strings.Clone) to keep it. A KeyGen is
+// not safe for concurrent use.
+type KeyGen struct {
+	prefix string
+	next   uint64
+	buf    []byte
+}
+
+// NewKeyGen returns a generator whose first key is prefix followed by 0.
+func NewKeyGen(prefix string) *KeyGen {
+	return &KeyGen{
+		prefix: prefix,
+		buf:    make([]byte, 0, len(prefix)+20),
+	}
+}
+
+// Next returns the next key in the stream.
+func (g *KeyGen) Next() string {
+	g.buf = strconv.AppendUint(append(g.buf[:0], g.prefix...), g.next, 10)
+	g.next++
+	return unsafe.String(unsafe.SliceData(g.buf), len(g.buf))
+}
@@ -0,0 +1,43 @@
+package hashringtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/krisalay/distributed-systems-journal/hashring"
+)
+
+// KeyGen reproduces the fmt.Sprintf key stream
+func TestKeyGen(t *testing.T) {
+	g := NewKeyGen("key-")
+	for i := 0; i < 1_000; i++ {
+		if got, want := g.Next(), fmt.Sprintf("key-%d", i); got != want {
+			t.Fatalf("key %d = %q, want %q", i, got, want)
+		}
+	}
+}
+
+// ---------------- Benchmarks ----------------
+
+// BenchmarkGetNodeKeys compares GetNode fed by fmt.Sprintf with GetNode fed
+// by a KeyGen, showing the allocations the key formatting adds.
+func BenchmarkGetNodeKeys(b *testing.B) {
+	r := hashring.New()
+	for i := 0; i < 10; i++ {
+		r.AddNode(hashring.Node(fmt.Sprintf("n%d", i)))
+	}
+
+	b.Run("sprintf", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = r.GetNode(fmt.Sprintf("key-%d", i))
+		}
+	})
+	b.Run("keygen", func(b *testing.B) {
+		g := NewKeyGen("key-")
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = r.GetNode(g.Next())
+		}
+	})
+}
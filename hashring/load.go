@@ -0,0 +1,100 @@
+package hashring
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// loadTracker counts the live assignments per node reported through Inc
+// and Dec. The counters are atomic, so bounded lookups stay lock-free under
+// CopyOnWrite and load updates never contend with ring mutations. The ring
+// shares its tracker with the snapshots it publishes.
+type loadTracker struct {
+	loads sync.Map // Node -> *atomic.Int64
+	total atomic.Int64
+}
+
+// counter returns n's load counter, creating it on first use.
+func (t *loadTracker) counter(n Node) *atomic.Int64 {
+	if c, ok := t.loads.Load(n); ok {
+		return c.(*atomic.Int64)
+	}
+	c, _ := t.loads.LoadOrStore(n, new(atomic.Int64))
+	return c.(*atomic.Int64)
+}
+
+// get returns n's current load.
+func (t *loadTracker) get(n Node) int64 {
+	if c, ok := t.loads.Load(n); ok {
+		return c.(*atomic.Int64).Load()
+	}
+	return 0
+}
+
+// WithBoundedLoad enables consistent hashing with bounded loads.
+//
+// GetNode normally returns the first node clockwise from the key. With a
+// load factor c, a node whose current load (see Inc) already reaches
+// ceil(c * (total+1) * weight / totalWeight) is skipped and the walk moves on
+// to the next node under its share, so a few hot keys cannot pile onto one
+// node. If callers Inc the returned node for every key they route, no node
+// ever exceeds ceil(c * average) assignments, scaled by its weight. Factors
+// below 1 cannot be satisfied and are ignored.
+func WithBoundedLoad(factor float64) Option {
+	return func(h *HashRing) {
+		if factor >= 1 {
+			h.loadFactor = factor
+		}
+	}
+}
+
+// Inc records one more assignment routed to n.
+func (h *HashRing) Inc(n Node) {
+	h.load.counter(n).Add(1)
+	h.load.total.Add(1)
+}
+
+// Dec records that an assignment routed to n has finished. Loads never go
+// below zero.
+func (h *HashRing) Dec(n Node) {
+	c := h.load.counter(n)
+	for {
+		v := c.Load()
+		if v <= 0 {
+			return
+		}
+		if c.CompareAndSwap(v, v-1) {
+			h.load.total.Add(-1)
+			return
+		}
+	}
+}
+
+// Load returns the current assignment count of n.
+func (h *HashRing) Load(n Node) int64 {
+	return h.load.get(n)
+}
+
+// boundedOwner returns the first node clockwise from key whose load is
+// below its bounded share, or the unbounded owner when every node is at
+// capacity. Callers must hold the read lock.
+func (h *HashRing) boundedOwner(key string) Node {
+	total := h.load.total.Load()
+	var first, owner Node
+	h.walk(key, func(n Node) bool {
+		if first == "" {
+			first = n
+		}
+		share := float64(total+1) * float64(h.nodes[n]) / float64(h.totalWeight)
+		if h.load.get(n) < int64(math.Ceil(h.loadFactor*share)) {
+			owner = n
+			return false
+		}
+		return true
+	})
+	if owner == "" {
+		return first
+	}
+	return owner
+}
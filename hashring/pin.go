@@ -0,0 +1,77 @@
+package hashring
+
+// Pin routes key to preferred ahead of the ring for as long as preferred is
+// a member and not marked down; otherwise GetNode falls back to normal ring
+// routing. Pins survive membership changes, so a hot key returns to its warm
+// node as soon as that node is back. Pinning the key again replaces the
+// preferred node.
+//
+// Pins apply to primary lookups (GetNode, GroupByNode, ResolveCached);
+// replica lookups and FrozenRing route by the ring alone.
+func (h *HashRing) Pin(key string, preferred Node) {
+	h.mu.Lock()
+	if h.pins == nil {
+		h.pins = make(map[string]Node)
+	}
+	h.pins[key] = preferred
+	h.gen++
+	h.unlockAndNotify()
+}
+
+// Unpin removes key's override. Unpinning a key that is not pinned is a
+// no-op.
+func (h *HashRing) Unpin(key string) {
+	h.mu.Lock()
+	if _, ok := h.pins[key]; ok {
+		delete(h.pins, key)
+		h.gen++
+	}
+	h.unlockAndNotify()
+}
+
+// MarkDown marks n unhealthy so keys pinned to it route normally. It does
+// not change ring placement: n keeps its vnodes and its unpinned keys. The
+// mark persists across removal and re-addition until MarkUp.
+func (h *HashRing) MarkDown(n Node) {
+	h.setDown(n, true)
+}
+
+// MarkUp clears a MarkDown, restoring n's pinned keys.
+func (h *HashRing) MarkUp(n Node) {
+	h.setDown(n, false)
+}
+
+func (h *HashRing) setDown(n Node, down bool) {
+	h.mu.Lock()
+	if _, ok := h.down[n]; ok != down {
+		if down {
+			if h.down == nil {
+				h.down = make(map[Node]struct{})
+			}
+			h.down[n] = struct{}{}
+		} else {
+			delete(h.down, n)
+		}
+		h.gen++
+	}
+	h.unlockAndNotify()
+}
+
+// pinned returns key's preferred node if it is pinned to a live member.
+// Callers must hold the read lock.
+func (h *HashRing) pinned(key string) (Node, bool) {
+	if len(h.pins) == 0 {
+		return "", false
+	}
+	n, ok := h.pins[key]
+	if !ok {
+		return "", false
+	}
+	if _, member := h.nodes[n]; !member {
+		return "", false
+	}
+	if _, down := h.down[n]; down {
+		return "", false
+	}
+	return n, true
+}
@@ -0,0 +1,126 @@
+package hashring
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// RendezvousRing routes keys with weighted rendezvous (highest random
+// weight) hashing instead of a ring of virtual nodes.
+//
+// Every lookup scores each node against the key and picks the highest
+// score, so there is no per-node placement memory and the distribution is
+// as even as the hash itself, which matters most for small clusters where a
+// vnode ring stays visibly uneven. The price is lookups linear in the node
+// count. Adding or removing a node only moves the keys that node wins or
+// held, about 1/N of them, matching the consistent hashing guarantee.
+//
+// A RendezvousRing is safe for concurrent use.
+type RendezvousRing struct {
+	mu     sync.RWMutex
+	hasher Hasher
+	nodes  map[Node]int
+}
+
+// NewRendezvous returns an empty RendezvousRing hashing with hasher, or
+// with CRC32 when hasher is nil.
+func NewRendezvous(hasher Hasher) *RendezvousRing {
+	if hasher == nil {
+		hasher = crc32Hasher{}
+	}
+	return &RendezvousRing{hasher: hasher, nodes: make(map[Node]int)}
+}
+
+// AddNode adds a node with default weight = 1.
+func (r *RendezvousRing) AddNode(n Node) {
+	r.AddNodeWeighted(n, 1)
+}
+
+// AddNodeWeighted adds a node with a specified weight; a node with weight 2
+// wins about twice as many keys as one with weight 1. Adding a node that is
+// already present changes its weight. Non-positive weights are ignored.
+func (r *RendezvousRing) AddNodeWeighted(n Node, weight int) {
+	if weight <= 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nodes[n] = weight
+}
+
+// RemoveNode removes a node. Only the keys it owned move.
+func (r *RendezvousRing) RemoveNode(n Node) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.nodes, n)
+}
+
+// GetNode returns the node with the highest score for key, or "" when the
+// ring is empty.
+func (r *RendezvousRing) GetNode(key string) Node {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var best Node
+	bestScore := math.Inf(-1)
+	for n, w := range r.nodes {
+		s := r.score(key, n, w)
+		if s > bestScore || (s == bestScore && n < best) {
+			best, bestScore = n, s
+		}
+	}
+	return best
+}
+
+// GetNodes returns up to replicas distinct nodes for key in descending
+// score order. The first is GetNode's answer, and removing any node leaves
+// the relative order of the others unchanged.
+func (r *RendezvousRing) GetNodes(key string, replicas int) []Node {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if replicas <= 0 || len(r.nodes) == 0 {
+		return nil
+	}
+
+	type scored struct {
+		node  Node
+		score float64
+	}
+	all := make([]scored, 0, len(r.nodes))
+	for n, w := range r.nodes {
+		all = append(all, scored{n, r.score(key, n, w)})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].score != all[j].score {
+			return all[i].score > all[j].score
+		}
+		return all[i].node < all[j].node
+	})
+
+	nodes := make([]Node, min(replicas, len(all)))
+	for i := range nodes {
+		nodes[i] = all[i].node
+	}
+	return nodes
+}
+
+// score returns the weighted rendezvous score of n for key.
+//
+// Multiplying a uniform hash by the weight does not yield proportional
+// ownership, so the logarithmic method is used instead: with u the hash of
+// key and n mapped into (0, 1), -weight/ln(u) makes each node win with
+// probability weight/totalWeight. The hash is run through the murmur3
+// finalizer first so that linear hashes such as CRC32 do not correlate the
+// scores of different nodes.
+func (r *RendezvousRing) score(key string, n Node, weight int) float64 {
+	buf := make([]byte, 0, len(key)+len(n)+1)
+	buf = append(buf, key...)
+	buf = append(buf, 0)
+	buf = append(buf, n...)
+	x := mix32(r.hasher.Sum32(buf), 0)
+
+	u := (float64(x) + 1) / (math.MaxUint32 + 2)
+	return -float64(weight) / math.Log(u)
+}
@@ -0,0 +1,100 @@
+package hashring
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// Rendezvous hashing splits keys evenly across a small cluster
+func TestRendezvousBalance(t *testing.T) {
+	r := NewRendezvous(nil)
+	for i := 0; i < 4; i++ {
+		r.AddNode(Node(fmt.Sprintf("n%d", i)))
+	}
+	r.AddNodeWeighted("big", 2)
+
+	const N = 120_000
+	count := make(map[Node]int)
+	for i := 0; i < N; i++ {
+		count[r.GetNode(fmt.Sprintf("key-%d", i))]++
+	}
+	for n, c := range count {
+		want := 1.0 / 6
+		if n == "big" {
+			want = 2.0 / 6
+		}
+		if share := float64(c) / N; math.Abs(share-want) > 0.01 {
+			t.Fatalf("%s owns %.4f of keys, want %.4f", n, share, want)
+		}
+	}
+}
+
+// Adding a node moves only the keys it wins, about 1/N of them
+func TestRendezvousMinimalRemap(t *testing.T) {
+	r := NewRendezvous(nil)
+	for i := 0; i < 4; i++ {
+		r.AddNode(Node(fmt.Sprintf("n%d", i)))
+	}
+
+	const N = 50_000
+	before := make([]Node, N)
+	for i := range before {
+		before[i] = r.GetNode(fmt.Sprintf("key-%d", i))
+	}
+	r.AddNode("n4")
+
+	moved := 0
+	for i := range before {
+		got := r.GetNode(fmt.Sprintf("key-%d", i))
+		if got == before[i] {
+			continue
+		}
+		if got != "n4" {
+			t.Fatalf("key-%d moved between existing nodes: %s -> %s", i, before[i], got)
+		}
+		moved++
+	}
+	if frac := float64(moved) / N; math.Abs(frac-0.2) > 0.02 {
+		t.Fatalf("moved %.3f of keys, want about 0.2", frac)
+	}
+
+	r.RemoveNode("n4")
+	for i := range before {
+		if got := r.GetNode(fmt.Sprintf("key-%d", i)); got != before[i] {
+			t.Fatalf("key-%d not restored after removal", i)
+		}
+	}
+}
+
+// GetNodes lists distinct nodes by score, led by GetNode
+func TestRendezvousGetNodes(t *testing.T) {
+	r := NewRendezvous(nil)
+	if r.GetNode("k") != "" || r.GetNodes("k", 2) != nil {
+		t.Fatalf("empty ring returned nodes")
+	}
+	for i := 0; i < 5; i++ {
+		r.AddNode(Node(fmt.Sprintf("n%d", i)))
+	}
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		nodes := r.GetNodes(key, 3)
+		if len(nodes) != 3 || nodes[0] != r.GetNode(key) {
+			t.Fatalf("%s: got %v", key, nodes)
+		}
+		if nodes[0] == nodes[1] || nodes[1] == nodes[2] || nodes[0] == nodes[2] {
+			t.Fatalf("%s: duplicate replicas %v", key, nodes)
+		}
+
+		// Dropping the primary promotes the remaining replicas in order
+		r.RemoveNode(nodes[0])
+		if got := r.GetNodes(key, 2); fmt.Sprint(got) != fmt.Sprint(nodes[1:]) {
+			t.Fatalf("%s: after removing %s got %v, want %v", key, nodes[0], got, nodes[1:])
+		}
+		r.AddNode(nodes[0])
+	}
+	if got := r.GetNodes("k", 10); len(got) != 5 {
+		t.Fatalf("got %d replicas, want all 5 nodes", len(got))
+	}
+}
@@ -0,0 +1,73 @@
+package hashring
+
+import (
+	"math/rand/v2"
+	"runtime"
+	"sync/atomic"
+)
+
+// cacheLineSize is the padding unit that keeps snapshot shards from
+// sharing a cache line.
+const cacheLineSize = 64
+
+// snapShard holds one copy of the published ring under ShardedCopyOnWrite.
+type snapShard struct {
+	ring atomic.Pointer[HashRing]
+	_    [cacheLineSize - 8]byte
+}
+
+// WithSnapshotShards sets the number of ring copies kept under
+// ShardedCopyOnWrite. The default is GOMAXPROCS at construction time.
+// Non-positive values keep the default; other strategies ignore it.
+func WithSnapshotShards(n int) Option {
+	return func(h *HashRing) {
+		if n > 0 {
+			h.snapShardCount = n
+		}
+	}
+}
+
+// initSnapshots allocates the snapshot shards for ShardedCopyOnWrite.
+func (h *HashRing) initSnapshots() {
+	if h.strategy != ShardedCopyOnWrite {
+		return
+	}
+	n := h.snapShardCount
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	h.snapShards = make([]snapShard, n)
+}
+
+// publish makes the current ring visible to lock-free readers according to
+// the rebuild strategy. Callers must hold the write lock.
+func (h *HashRing) publish() {
+	switch h.strategy {
+	case CopyOnWrite:
+		h.snap.Store(h.snapshot())
+	case ShardedCopyOnWrite:
+		for i := range h.snapShards {
+			h.snapShards[i].ring.Store(h.snapshot())
+		}
+	}
+}
+
+// snapshot returns a clone for lock-free readers. Unlike other clones it
+// shares the ring's load tracker, so bounded lookups on it see live load.
+// Callers must hold the write lock.
+func (h *HashRing) snapshot() *HashRing {
+	c := h.clone()
+	c.load = h.load
+	return c
+}
+
+// loadSnap returns a published immutable ring to read from without
+// locking, or nil under InPlace. Under ShardedCopyOnWrite a shard is
+// picked with the runtime's per-thread random source, which needs no
+// shared state.
+func (h *HashRing) loadSnap() *HashRing {
+	if n := len(h.snapShards); n > 0 {
+		return h.snapShards[rand.IntN(n)].ring.Load()
+	}
+	return h.snap.Load()
+}
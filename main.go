@@ -7,13 +7,10 @@ import (
 )
 
 func main() {
-	r := hashring.New()
-	r.AddNode("A")
-	r.AddNode("B")
-	r.AddNode("C")
+	r := hashring.NewReplicated([]hashring.Node{"A", "B", "C"}, 2, hashring.DefaultVirtualNodes)
 
 	for _, k := range []string{"user:1", "user:2", "user:3"} {
 		fmt.Println(k, "->", r.GetNode(k))
-		fmt.Println(k, "replicas ->", r.GetNodes(k, 2))
+		fmt.Println(k, "replicas ->", r.Route(k))
 	}
 }